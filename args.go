@@ -0,0 +1,74 @@
+package kongcompletion
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/posener/complete"
+)
+
+// The code below is taken from https://github.com/posener/complete/blob/f6dd29e97e24f8cb51a8d4050781ce2b238776a4/args.go
+// to allow tokenizing command lines here. (The original function is not exported.)
+
+func newArgs(line string) complete.Args {
+	return argsFromFields(splitFields(line))
+}
+
+// argsFromFields turns the fields of a command line, the first of which is the
+// name of the binary, into completion arguments.
+func argsFromFields(parts []string) complete.Args {
+	var (
+		all       []string
+		completed []string
+	)
+	if len(parts) > 0 {
+		all = parts[1:]
+		completed = removeLast(parts[1:])
+	}
+	return complete.Args{
+		All:           all,
+		Completed:     completed,
+		Last:          last(parts),
+		LastCompleted: last(completed),
+	}
+}
+
+// splitFields returns a list of fields from the given command line.
+// If the last character is space, it appends an empty field in the end
+// indicating that the field before it was completed.
+// If the last field is of the form "a=b", it splits it to two fields: "a", "b",
+// So it can be completed.
+func splitFields(line string) []string {
+	parts := strings.Fields(line)
+
+	// Add empty field if the last field was completed.
+	if len(line) > 0 && unicode.IsSpace(rune(line[len(line)-1])) {
+		parts = append(parts, "")
+	}
+
+	// Treat the last field if it is of the form "a=b"
+	parts = splitLastEqual(parts)
+	return parts
+}
+
+func splitLastEqual(line []string) []string {
+	if len(line) == 0 {
+		return line
+	}
+	parts := strings.Split(line[len(line)-1], "=")
+	return append(line[:len(line)-1], parts...)
+}
+
+func removeLast(a []string) []string {
+	if len(a) > 0 {
+		return a[:len(a)-1]
+	}
+	return a
+}
+
+func last(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[len(args)-1]
+}
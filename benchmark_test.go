@@ -0,0 +1,86 @@
+package kongcompletion
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+)
+
+// largeApp generates a kong app with groups×commands subcommands, each of
+// which has a couple of aliases, flags and a positional argument.
+func largeApp(b *testing.B, groups, commands int) *kong.Kong {
+	b.Helper()
+	leafFields := []reflect.StructField{
+		{Name: "Arg", Type: reflect.TypeFor[string](), Tag: `kong:"arg,optional,completion-predictor=names"`},
+	}
+	for i := range 8 {
+		leafFields = append(leafFields, reflect.StructField{
+			Name: fmt.Sprintf("Flag%d", i),
+			Type: reflect.TypeFor[string](),
+			Tag:  reflect.StructTag(fmt.Sprintf(`kong:"completion-predictor=names" help:"Flag number %d."`, i)),
+		}, reflect.StructField{
+			Name: fmt.Sprintf("Switch%d", i),
+			Type: reflect.TypeFor[bool](),
+		})
+	}
+	leaf := reflect.StructOf(leafFields)
+
+	groupFields := make([]reflect.StructField, commands)
+	for i := range groupFields {
+		groupFields[i] = reflect.StructField{
+			Name: fmt.Sprintf("Cmd%d", i),
+			Type: leaf,
+			Tag:  reflect.StructTag(fmt.Sprintf(`kong:"cmd,name=cmd%d,aliases='c%d,x%d'" help:"Command number %d."`, i, i, i, i)),
+		}
+	}
+	group := reflect.StructOf(groupFields)
+
+	rootFields := make([]reflect.StructField, groups)
+	for i := range rootFields {
+		rootFields[i] = reflect.StructField{
+			Name: fmt.Sprintf("Group%d", i),
+			Type: group,
+			Tag:  reflect.StructTag(fmt.Sprintf(`kong:"cmd,name=group%d,aliases='g%d'"`, i, i)),
+		}
+	}
+	cli := reflect.New(reflect.StructOf(rootFields)).Interface()
+	parser, err := kong.New(cli, kong.Name("app"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return parser
+}
+
+func BenchmarkComplete(b *testing.B) {
+	names := WithPredictor("names", complete.PredictSet("Ben", "Liz"))
+	for _, size := range []struct{ groups, commands int }{
+		{groups: 10, commands: 10},
+		{groups: 50, commands: 50},
+	} {
+		parser := largeApp(b, size.groups, size.commands)
+		for _, line := range []string{"app ", "app group3 ", "app group3 cmd7 --flag-2 "} {
+			b.Run(fmt.Sprintf("%dx%d/%q", size.groups, size.commands, line), func(b *testing.B) {
+				for b.Loop() {
+					_, err := Complete(parser, line, len(line), names)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCommand(b *testing.B) {
+	names := WithPredictor("names", complete.PredictSet("Ben", "Liz"))
+	parser := largeApp(b, 50, 50)
+	for b.Loop() {
+		_, err := Command(parser, names)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package kongcompletion
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/posener/complete"
+)
+
+// PredictorCache is a predictor that stores the results of another predictor
+// on disk, so that they can be reused by subsequent completion requests.
+type PredictorCache struct {
+	// Name identifies the cached results. It must be unique per binary.
+	Name string
+
+	// TTL is the duration after which cached results are discarded.
+	TTL time.Duration
+
+	// Predictor is the predictor that computes the actual results.
+	Predictor complete.Predictor
+
+	// Key returns the context that the results depend on, e.g. the value of
+	// a preceding argument. If nil, the results are cached per word under the
+	// cursor, since predictors such as complete.PredictFiles depend on it. For
+	// results that don’t depend on the command line at all, return a constant.
+	Key func(complete.Args) string
+
+	// Dir is the directory of the cache files. It defaults to CacheDir.
+	Dir string
+
+	// BinName is the name of the binary that is passed to CacheDir. It
+	// defaults to the name of the kong app that requests the completions,
+	// like for ClearCache. Outside of completion requests (e.g. for Clear),
+	// it falls back to the name of the executable.
+	BinName string
+}
+
+type cacheEntry struct {
+	Expires    time.Time `json:"expires"`
+	Candidates []string  `json:"candidates"`
+}
+
+// CachedPredictor caches the results of predictor for the duration of ttl
+func CachedPredictor(name string, ttl time.Duration, predictor complete.Predictor) *PredictorCache {
+	return &PredictorCache{
+		Name:      name,
+		TTL:       ttl,
+		Predictor: predictor,
+	}
+}
+
+// CacheDir returns the directory where the completion results of the binary
+// are cached, i.e. `$XDG_CACHE_HOME/<binName>/completion` on Linux.
+func CacheDir(binName string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, binName, "completion"), nil
+}
+
+// ClearCache removes all cached completion results of the binary.
+func ClearCache(binName string) error {
+	dir, err := CacheDir(binName)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// Predict implements complete.Predictor
+func (c *PredictorCache) Predict(a complete.Args) []string {
+	return c.PredictContext(context.Background(), a)
+}
+
+// PredictContext implements ContextPredictor
+func (c *PredictorCache) PredictContext(ctx context.Context, a complete.Args) []string {
+	if name, ok := appName(ctx); ok && c.BinName == "" {
+		bound := *c
+		bound.BinName = name
+		c = &bound
+	}
+	path, pathErr := c.path(a)
+	if pathErr == nil {
+		if candidates, ok := readCacheEntry(path); ok {
+			return candidates
+		}
+	}
+	candidates, err := predictContext(ctx, c.Predictor, a)
+	if err != nil || pathErr != nil {
+		// Partial results must not be cached.
+		return candidates
+	}
+	_ = writeCacheEntry(path, cacheEntry{
+		Expires:    time.Now().Add(c.TTL),
+		Candidates: candidates,
+	})
+	c.removeExpired()
+	return candidates
+}
+
+// Clear removes all cached results of this predictor.
+func (c *PredictorCache) Clear() error {
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeExpired removes the expired results of this predictor, so that the
+// cache doesn’t grow without bounds if the results are keyed.
+func (c *PredictorCache) removeExpired() {
+	files, err := c.files()
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		_, _ = readCacheEntry(f)
+	}
+}
+
+// files returns the cache files of this predictor.
+func (c *PredictorCache) files() ([]string, error) {
+	dir, err := c.dir()
+	if err != nil {
+		return nil, err
+	}
+	// The hash in the file name has a fixed length, which avoids matching the
+	// files of other predictors whose names start with the same prefix.
+	return filepath.Glob(filepath.Join(dir, cacheFilePrefix(c.Name)+strings.Repeat("?", 16)+".json"))
+}
+
+func (c *PredictorCache) dir() (string, error) {
+	if c.Dir != "" {
+		return c.Dir, nil
+	}
+	if c.BinName != "" {
+		return CacheDir(c.BinName)
+	}
+	return CacheDir(filepath.Base(os.Args[0]))
+}
+
+func (c *PredictorCache) path(a complete.Args) (string, error) {
+	dir, err := c.dir()
+	if err != nil {
+		return "", err
+	}
+	key := a.Last
+	if c.Key != nil {
+		key = c.Key(a)
+	}
+	hash := sha256.Sum256([]byte(c.Name + "\x00" + key))
+	return filepath.Join(dir, cacheFilePrefix(c.Name)+hex.EncodeToString(hash[:8])+".json"), nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^[:alnum:]_.-]`)
+
+func cacheFilePrefix(name string) string {
+	return unsafeFileNameChars.ReplaceAllString(name, "_") + "-"
+}
+
+// readCacheEntry returns the cached results, unless they are expired. In that
+// case, the file is removed.
+func readCacheEntry(path string) ([]string, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, false
+	}
+	if time.Now().After(entry.Expires) {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	return entry.Candidates, true
+}
+
+// writeCacheEntry writes the entry atomically, so that concurrent completion
+// requests never see partially written files.
+func writeCacheEntry(path string, entry cacheEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
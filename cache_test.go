@@ -0,0 +1,97 @@
+package kongcompletion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedPredictor(t *testing.T) {
+	calls := 0
+	cache := CachedPredictor("things", time.Hour, complete.PredictFunc(func(a complete.Args) []string {
+		calls++
+		return []string{"thing", a.LastCompleted}
+	}))
+	cache.Dir = t.TempDir()
+
+	t.Run("reuses results", func(t *testing.T) {
+		assert.Equal(t, []string{"thing", "a"}, cache.Predict(newArgs("app a ")))
+		assert.Equal(t, []string{"thing", "a"}, cache.Predict(newArgs("app b ")))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("keys results by the word under the cursor", func(t *testing.T) {
+		assert.Equal(t, []string{"thing", "a"}, cache.Predict(newArgs("app a t")))
+		assert.Equal(t, []string{"thing", "a"}, cache.Predict(newArgs("app b t")))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("keys results", func(t *testing.T) {
+		cache.Key = func(a complete.Args) string { return a.LastCompleted }
+		defer func() { cache.Key = nil }()
+		assert.Equal(t, []string{"thing", "b"}, cache.Predict(newArgs("app b ")))
+		assert.Equal(t, []string{"thing", "b"}, cache.Predict(newArgs("app b ")))
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("clears results", func(t *testing.T) {
+		require.NoError(t, cache.Clear())
+		assert.Equal(t, []string{"thing", "c"}, cache.Predict(newArgs("app c ")))
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("expires results", func(t *testing.T) {
+		cache.TTL = -time.Second
+		defer func() { cache.TTL = time.Hour }()
+		require.NoError(t, cache.Clear())
+		cache.Predict(newArgs("app d "))
+		cache.Predict(newArgs("app d "))
+		assert.Equal(t, 6, calls)
+	})
+
+	t.Run("removes expired results", func(t *testing.T) {
+		cache.TTL = -time.Second
+		defer func() { cache.TTL = time.Hour }()
+		for _, word := range []string{"x", "y", "z"} {
+			cache.Predict(newArgs("app " + word))
+		}
+		files, err := cache.files()
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+}
+
+func TestCachedPredictorUsesAppName(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var cli struct {
+		Name string `arg:"" completion-predictor:"names"`
+	}
+	parser := kong.Must(&cli, kong.Name("greet"))
+	calls := 0
+	cache := CachedPredictor("names", time.Hour, complete.PredictFunc(func(complete.Args) []string {
+		calls++
+		return []string{"Ben"}
+	}))
+
+	for range 2 {
+		got, err := Complete(parser, "renamed-greet ", 14, WithPredictor("names", cache))
+		require.NoError(t, err)
+		assert.Equal(t, []Candidate{{Value: "Ben"}}, got)
+	}
+	assert.Equal(t, 1, calls)
+
+	require.NoError(t, ClearCache("greet"))
+	_, err := Complete(parser, "renamed-greet ", 14, WithPredictor("names", cache))
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls, "ClearCache removes the results")
+
+	cache.BinName = "greet"
+	require.NoError(t, cache.Clear())
+	_, err = Complete(parser, "renamed-greet ", 14, WithPredictor("names", cache))
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls, "Clear removes the results")
+}
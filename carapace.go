@@ -0,0 +1,206 @@
+package kongcompletion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// CarapaceSpec returns a completion spec of a kong app in the YAML format of
+// carapace-spec (https://carapace.sh), which provides completions for a
+// variety of shells. Values with a completion-predictor are completed by
+// invoking the binary, so Register must be called by the app.
+func CarapaceSpec(parser *kong.Kong, opt ...ScriptOption) (string, error) {
+	data := buildTemplateData(parser, opt...)
+	root, err := buildModel(parser)
+	if err != nil {
+		return "", err
+	}
+	root.Name = data.BinName
+	b := &strings.Builder{}
+	b.WriteString("# yaml-language-server: $schema=https://carapace.sh/schemas/command.json\n")
+	fmt.Fprintf(b, "# Completion spec for %s, generated from its command-line model.\n", data.BinName)
+	writeCarapaceCommand(b, root, data, nil, "")
+	return b.String(), nil
+}
+
+// WriteCarapaceSpec writes the output of CarapaceSpec to w.
+func WriteCarapaceSpec(w io.Writer, parser *kong.Kong, opt ...ScriptOption) error {
+	spec, err := CarapaceSpec(parser, opt...)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, spec)
+	return err
+}
+
+// writeCarapaceCommand writes the command at the given indentation. path holds
+// the names of the subcommands that lead to the command.
+func writeCarapaceCommand(b *strings.Builder, cmd *modelCommand, data templateData, path []string, indent string) {
+	w := func(format string, a ...any) {
+		fmt.Fprintf(b, indent+format+"\n", a...)
+	}
+	w("name: %s", yamlQuote(cmd.Name))
+	if len(cmd.Aliases) > 0 {
+		w("aliases: %s", yamlList(cmd.Aliases))
+	}
+	if cmd.Help != "" {
+		w("description: %s", yamlQuote(firstLine(cmd.Help)))
+	}
+
+	// The flags of a kong command apply to its subcommands as well.
+	flagsKey := "flags"
+	if len(cmd.Commands) > 0 {
+		flagsKey = "persistentflags"
+	}
+	if len(cmd.Flags) > 0 {
+		w("%s:", flagsKey)
+		for _, flag := range cmd.Flags {
+			for _, spelling := range carapaceFlagSpellings(flag) {
+				w("  %s: %s", yamlQuote(spelling), yamlQuote(firstLine(flag.Help)))
+			}
+		}
+	}
+
+	flagActions := map[string][]string{}
+	var flagNames []string
+	for _, flag := range cmd.Flags {
+		if flag.Bool {
+			continue
+		}
+		action := carapaceAction(flag.Value, data, append(path, "--"+flag.Name), nil)
+		if action == nil {
+			continue
+		}
+		for _, name := range append([]string{flag.Name}, flag.Aliases...) {
+			flagNames = append(flagNames, name)
+			flagActions[name] = action
+		}
+	}
+	var positional [][]string
+	var positionalAny []string
+	for i, arg := range cmd.Args {
+		action := carapaceAction(arg, data, path, carapacePreviousArgs(i))
+		if i == len(cmd.Args)-1 && arg.Cumulative {
+			positionalAny = action
+			break
+		}
+		positional = append(positional, action)
+	}
+	for len(positional) > 0 && positional[len(positional)-1] == nil {
+		positional = positional[:len(positional)-1]
+	}
+	if len(flagNames) > 0 || len(positional) > 0 || positionalAny != nil {
+		w("completion:")
+		if len(flagNames) > 0 {
+			w("  flag:")
+			for _, name := range flagNames {
+				w("    %s: %s", yamlQuote(name), yamlList(flagActions[name]))
+			}
+		}
+		if len(positional) > 0 {
+			w("  positional:")
+			for _, action := range positional {
+				w("    - %s", yamlList(action))
+			}
+		}
+		if positionalAny != nil {
+			w("  positionalany: %s", yamlList(positionalAny))
+		}
+	}
+
+	if len(cmd.Commands) > 0 {
+		w("commands:")
+		for _, sub := range cmd.Commands {
+			b.WriteString(indent + "  -")
+			sb := &strings.Builder{}
+			writeCarapaceCommand(sb, sub, data, append(path[:len(path):len(path)], sub.Name), indent+"    ")
+			// The first line of the item goes after the list marker.
+			b.WriteString(" " + strings.TrimPrefix(sb.String(), indent+"    "))
+		}
+	}
+}
+
+// carapaceFlagSpellings returns the flag in the notation of carapace, where
+// the suffix `=` denotes that the flag takes a value, `*` that it can be
+// repeated, and `!` that it is required.
+func carapaceFlagSpellings(flag modelFlag) []string {
+	modifiers := ""
+	if !flag.Bool {
+		modifiers += "="
+	}
+	if flag.Value.Cumulative {
+		modifiers += "*"
+	}
+	if flag.Value.Required {
+		modifiers += "!"
+	}
+	spelling := "--" + flag.Name
+	if flag.Short != 0 {
+		spelling = "-" + string(flag.Short) + ", " + spelling
+	}
+	spellings := []string{spelling + modifiers}
+	for _, alias := range flag.Aliases {
+		spellings = append(spellings, "--"+alias+modifiers)
+	}
+	return spellings
+}
+
+// carapacePreviousArgs returns references to the values of the positional
+// arguments before the one at the index.
+func carapacePreviousArgs(index int) []string {
+	args := make([]string, index)
+	for i := range args {
+		args[i] = fmt.Sprintf(`"${C_ARG%d}"`, i)
+	}
+	return args
+}
+
+// carapaceAction returns the completion action of a value, or nil if it can’t
+// be completed. Predictors are invoked via completeCmd, with the words of the
+// command line reconstructed from the command path, the given arguments, and
+// the value under the cursor.
+func carapaceAction(v modelValue, data templateData, path []string, args []string) []string {
+	switch {
+	case v.Predictor != "":
+		words := []string{data.ProtocolEnv(), shellQuote(data.BinPath), completeCmd}
+		for _, name := range path {
+			words = append(words, shellQuote(name))
+		}
+		words = append(words, args...)
+		words = append(words, `"${C_VALUE}"`)
+		return []string{"$(" + strings.Join(words, " ") + ")"}
+	case v.Command != nil:
+		words := make([]string, len(v.Command))
+		for i, word := range v.Command {
+			words[i] = shellQuote(word)
+		}
+		return []string{"$(" + strings.Join(words, " ") + ")"}
+	case v.Values != nil:
+		return v.Values
+	case v.Hint == hintFile:
+		return []string{"$files"}
+	case v.Hint == hintDir:
+		return []string{"$directories"}
+	}
+	return nil
+}
+
+// yamlQuote quotes a string as a double-quoted YAML scalar, whose escape
+// sequences are a superset of those of JSON.
+func yamlQuote(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// yamlList renders a list of strings in YAML’s flow style.
+func yamlList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = yamlQuote(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,71 @@
+package kongcompletion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCarapaceSpec(t *testing.T) {
+	var cli struct {
+		staticApp
+		Send struct {
+			To   []string `short:"t" aliases:"recipient" required:"" completion-predictor:"users"`
+			Body string   `arg:"" optional:""`
+		} `cmd:""`
+	}
+	parser := kong.Must(&cli, kong.Name("app"))
+	got, err := CarapaceSpec(parser, WithBinPath("/opt/my app"))
+	require.NoError(t, err)
+
+	type command struct {
+		Name            string
+		Aliases         []string
+		Description     string
+		Flags           map[string]string
+		PersistentFlags map[string]string
+		Completion      struct {
+			Flag          map[string][]string
+			Positional    [][]string
+			PositionalAny []string
+		}
+		Commands []command
+	}
+	var spec command
+	require.NoError(t, yaml.Unmarshal([]byte(got), &spec))
+
+	assert.Equal(t, "app", spec.Name)
+	assert.Equal(t, map[string]string{
+		"-h, --help":    "Show context-sensitive help.",
+		"-v, --verbose": "",
+	}, spec.PersistentFlags)
+	require.Len(t, spec.Commands, 3)
+
+	open := spec.Commands[0]
+	assert.Equal(t, []string{"o"}, open.Aliases)
+	assert.Equal(t, "Open some files.", open.Description)
+	assert.Equal(t, map[string]string{"--config=": "The config file.", "--into=": "", "--mode=": ""}, open.Flags)
+	assert.Equal(t, map[string][]string{"config": {"$files"}, "into": {"$directories"}, "mode": {"read", "write"}}, open.Completion.Flag)
+	assert.Equal(t, []string{"$files"}, open.Completion.PositionalAny)
+
+	tag := spec.Commands[1]
+	assert.Equal(t, [][]string{
+		{"$(git tag --list)"},
+		{"red", "green"},
+		{`$(KONG_COMPLETION_PROTOCOL=1 '/opt/my app' __complete tag "${C_ARG0}" "${C_ARG1}" "${C_VALUE}")`},
+	}, tag.Completion.Positional)
+
+	send := spec.Commands[2]
+	assert.Equal(t, map[string]string{"-t, --to=*!": "", "--recipient=*!": ""}, send.Flags)
+	predictTo := []string{`$(KONG_COMPLETION_PROTOCOL=1 '/opt/my app' __complete send --to "${C_VALUE}")`}
+	assert.Equal(t, map[string][]string{"to": predictTo, "recipient": predictTo}, send.Completion.Flag)
+	assert.Empty(t, send.Completion.Positional)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCarapaceSpec(&buf, parser, WithBinPath("/opt/my app")))
+	assert.Equal(t, got, buf.String())
+}
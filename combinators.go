@@ -0,0 +1,155 @@
+package kongcompletion
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/posener/complete"
+)
+
+// The combinators below wrap other predictors. They are context-aware, so
+// that deadlines are passed on to the wrapped predictors (see WithTimeout).
+
+// PredictFilter only keeps the results for which keep returns true
+func PredictFilter(predictor complete.Predictor, keep func(candidate string) bool) ContextPredictFunc {
+	return func(ctx context.Context, a complete.Args) []string {
+		var result []string
+		for _, candidate := range predictMember(ctx, predictor, a) {
+			if keep(candidate) {
+				result = append(result, candidate)
+			}
+		}
+		return result
+	}
+}
+
+// PredictMatching only keeps the results that match the regular expression
+func PredictMatching(predictor complete.Predictor, re *regexp.Regexp) ContextPredictFunc {
+	return PredictFilter(predictor, re.MatchString)
+}
+
+// PredictMap transforms each result
+func PredictMap(predictor complete.Predictor, transform func(candidate string) string) ContextPredictFunc {
+	return func(ctx context.Context, a complete.Args) []string {
+		// Predictors may return their internal slices, so these must not be modified.
+		result := slices.Clone(predictMember(ctx, predictor, a))
+		for i, candidate := range result {
+			result[i] = transform(candidate)
+		}
+		return result
+	}
+}
+
+// PredictPrefixed prepends prefix to each result, e.g. `@` for user handles
+func PredictPrefixed(predictor complete.Predictor, prefix string) ContextPredictFunc {
+	return PredictMap(predictor, func(candidate string) string {
+		return prefix + candidate
+	})
+}
+
+// PredictLimit returns at most n of the results that match the word under
+// the cursor. It panics if n is negative.
+func PredictLimit(predictor complete.Predictor, n int) ContextPredictFunc {
+	if n < 0 {
+		panic(fmt.Sprintf("PredictLimit: negative limit %d", n))
+	}
+	return func(ctx context.Context, a complete.Args) []string {
+		result := matching(predictMember(ctx, predictor, a), a)
+		if len(result) > n {
+			result = result[:n]
+		}
+		return result
+	}
+}
+
+// PredictSorted sorts the results by the comparison function, which follows
+// the same convention as in slices.SortFunc. If cmp is nil, the results are
+// sorted alphabetically.
+func PredictSorted(predictor complete.Predictor, cmp func(a, b string) int) ContextPredictFunc {
+	if cmp == nil {
+		cmp = strings.Compare
+	}
+	return func(ctx context.Context, a complete.Args) []string {
+		result := slices.Clone(predictMember(ctx, predictor, a))
+		slices.SortStableFunc(result, cmp)
+		return result
+	}
+}
+
+// PredictDedupe removes duplicate results, retaining the first occurrence
+func PredictDedupe(predictor complete.Predictor) ContextPredictFunc {
+	return func(ctx context.Context, a complete.Args) []string {
+		var result []string
+		seen := map[string]bool{}
+		for _, candidate := range predictMember(ctx, predictor, a) {
+			if !seen[candidate] {
+				seen[candidate] = true
+				result = append(result, candidate)
+			}
+		}
+		return result
+	}
+}
+
+// PredictSwitch determines the predictor based on the command line, e.g. on
+// the values of the preceding arguments. If choose returns nil, nothing is
+// predicted.
+func PredictSwitch(choose func(a complete.Args) complete.Predictor) ContextPredictFunc {
+	return func(ctx context.Context, a complete.Args) []string {
+		return predictMember(ctx, choose(a), a)
+	}
+}
+
+// PredictIf uses then if the condition holds for the command line, and
+// otherwise the other predictor (which may be nil)
+func PredictIf(condition func(a complete.Args) bool, then complete.Predictor, otherwise complete.Predictor) ContextPredictFunc {
+	return PredictSwitch(func(a complete.Args) complete.Predictor {
+		if condition(a) {
+			return then
+		}
+		return otherwise
+	})
+}
+
+// PredictFallback returns the results of the first predictor that returns any
+// that match the word under the cursor
+func PredictFallback(predictors ...complete.Predictor) ContextPredictFunc {
+	return func(ctx context.Context, a complete.Args) []string {
+		for _, predictor := range predictors {
+			if result := matching(predictMember(ctx, predictor, a), a); len(result) > 0 {
+				return result
+			}
+		}
+		return nil
+	}
+}
+
+// predictMember invokes a predictor that is wrapped by a combinator.
+func predictMember(ctx context.Context, predictor complete.Predictor, a complete.Args) []string {
+	if predictor == nil {
+		return nil
+	}
+	result, err := predictContext(ctx, predictor, a)
+	if err != nil && !ctxErr(err) {
+		reportError(ctx, err)
+	}
+	return result
+}
+
+// matching only keeps the results that match the word under the cursor, the
+// same way as the completion engine does eventually.
+func matching(result []string, a complete.Args) []string {
+	if a.Last == "" {
+		return result
+	}
+	var kept []string
+	for _, candidate := range result {
+		if strings.HasPrefix(candidate, a.Last) {
+			kept = append(kept, candidate)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,64 @@
+package kongcompletion
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/posener/complete"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCombinators(t *testing.T) {
+	things := complete.PredictSet("b", "a10", "c", "a2", "b")
+	nothing := complete.PredictSet()
+	a := newArgs("app ")
+
+	for name, td := range map[string]struct {
+		predictor complete.Predictor
+		want      []string
+	}{
+		"filter":           {PredictFilter(things, func(c string) bool { return c != "b" }), []string{"a10", "c", "a2"}},
+		"matching":         {PredictMatching(things, regexp.MustCompile(`^a\d+$`)), []string{"a10", "a2"}},
+		"map":              {PredictMap(things, func(c string) string { return c + "!" }), []string{"b!", "a10!", "c!", "a2!", "b!"}},
+		"prefixed":         {PredictPrefixed(complete.PredictSet("x"), "@"), []string{"@x"}},
+		"limit":            {PredictLimit(things, 2), []string{"b", "a10"}},
+		"limit exceeding":  {PredictLimit(things, 10), []string{"b", "a10", "c", "a2", "b"}},
+		"limit (zero)":     {PredictLimit(things, 0), []string{}},
+		"sorted":           {PredictSorted(things, nil), []string{"a10", "a2", "b", "b", "c"}},
+		"sorted by length": {PredictSorted(things, func(x, y string) int { return len(x) - len(y) }), []string{"b", "c", "b", "a2", "a10"}},
+		"dedupe":           {PredictDedupe(things), []string{"b", "a10", "c", "a2"}},
+		"if (then)":        {PredictIf(func(complete.Args) bool { return true }, things, nil), []string{"b", "a10", "c", "a2", "b"}},
+		"if (otherwise)":   {PredictIf(func(complete.Args) bool { return false }, things, nil), nil},
+		"fallback":         {PredictFallback(nil, nothing, complete.PredictSet("x"), things), []string{"x"}},
+		"fallback (none)":  {PredictFallback(nothing), nil},
+		"composed":         {PredictLimit(PredictSorted(PredictDedupe(things), nil), 3), []string{"a10", "a2", "b"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := td.predictor.Predict(a)
+			assert.Equal(t, td.want, got)
+		})
+	}
+
+	t.Run("reject a negative limit", func(t *testing.T) {
+		assert.Panics(t, func() { PredictLimit(things, -1) })
+	})
+
+	t.Run("match the word under the cursor", func(t *testing.T) {
+		a := newArgs("app a")
+		assert.Equal(t, []string{"a10"}, PredictLimit(things, 1).Predict(a))
+		assert.Equal(t, []string{"a10", "a2"}, PredictFallback(complete.PredictSet("x"), things).Predict(a))
+		assert.Nil(t, PredictFallback(complete.PredictSet("x")).Predict(a))
+	})
+
+	t.Run("switch on preceding argument", func(t *testing.T) {
+		p := PredictSwitch(func(a complete.Args) complete.Predictor {
+			if slices.Contains(a.Completed, "--all") {
+				return things
+			}
+			return complete.PredictSet("c")
+		})
+		assert.Equal(t, []string{"c"}, p.Predict(newArgs("app ")))
+		assert.Len(t, p.Predict(newArgs("app --all ")), 5)
+	})
+}
@@ -0,0 +1,117 @@
+package kongcompletion
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/posener/complete"
+)
+
+const predictorCmdTag = "completion-predictor-cmd"
+
+// commandPredictorTimeout is the maximum time that external commands may run.
+const commandPredictorTimeout = 2 * time.Second
+
+// commandPredictor predicts the lines that an external command prints to
+// stdout. If the command fails, nothing is predicted.
+type commandPredictor struct {
+	args []string
+}
+
+func newCommandPredictor(commandLine string) (*commandPredictor, error) {
+	args, err := splitWords(commandLine)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return &commandPredictor{args: args}, nil
+}
+
+// Predict implements complete.Predictor
+func (p *commandPredictor) Predict(a complete.Args) []string {
+	return p.PredictContext(context.Background(), a)
+}
+
+// PredictContext implements ContextPredictor
+func (p *commandPredictor) PredictContext(ctx context.Context, a complete.Args) []string {
+	ctx, cancel := context.WithTimeout(ctx, commandPredictorTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, p.args[0], p.args[1:]...).Output()
+	if err != nil {
+		complete.Log("running predictor command %q failed: %v", p.args, err)
+		return nil
+	}
+	var result []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// splitWords splits a command line into words, similar to how a POSIX shell
+// would do it. It supports single and double quotes, and backslash escapes,
+// but no other shell features such as variables or pipes.
+func splitWords(s string) ([]string, error) {
+	words, _, quote, escaped := scanWords(s)
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if escaped {
+		return nil, errors.New("unterminated escape sequence")
+	}
+	return words, nil
+}
+
+// scanWords splits a command line the same way as splitWords, but tolerates
+// an unterminated last word, as it occurs while the word is being typed. It
+// returns the offset at which the last word starts (or len(s), if s doesn’t
+// end with a word), along with the unterminated quote or escape sequence.
+func scanWords(s string) (words []string, lastStart int, quote rune, escaped bool) {
+	var word strings.Builder
+	inWord := false
+	lastStart = len(s)
+	for i, r := range s {
+		if !inWord && quote == 0 && !escaped && r != ' ' && r != '\t' && r != '\n' {
+			lastStart = i
+		}
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			lastStart = len(s)
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, lastStart, quote, escaped
+}
@@ -0,0 +1,73 @@
+package kongcompletion
+
+import (
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandPredictor(t *testing.T) {
+	var cli struct {
+		Color  string `completion-predictor-cmd:"printf '%s\\n' red green"`
+		Greet  string `completion-predictor-cmd:"echo ${greeting}" set:"greeting=hello"`
+		Broken string `completion-predictor-cmd:"false"`
+		Absent string `completion-predictor-cmd:"this-command-does-not-exist"`
+	}
+
+	for _, td := range []completeTest{
+		{line: "myApp --color ", want: []string{"red", "green"}},
+		{line: "myApp --color g", want: []string{"green"}},
+		{line: "myApp --greet ", want: []string{"hello"}},
+		{line: "myApp --broken ", want: []string{}},
+		{line: "myApp --absent ", want: []string{}},
+	} {
+		t.Run(td.line, func(t *testing.T) {
+			got := runComplete(t, kong.Must(&cli), td.line, nil)
+			assert.ElementsMatch(t, td.want, got)
+		})
+	}
+}
+
+func Test_splitWords(t *testing.T) {
+	for input, want := range map[string][]string{
+		``:                         nil,
+		`git branch`:               {"git", "branch"},
+		`  git   branch  `:         {"git", "branch"},
+		`jq -r '.[] | .name' x.js`: {"jq", "-r", ".[] | .name", "x.js"},
+		`echo "a \"b\"" c\ d`:      {"echo", `a "b"`, "c d"},
+		`echo '' ""`:               {"echo", "", ""},
+		`echo 'a\b'`:               {"echo", `a\b`},
+	} {
+		t.Run(input, func(t *testing.T) {
+			got, err := splitWords(input)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	_, err := splitWords(`echo 'oops`)
+	assert.EqualError(t, err, "unterminated quote")
+}
+
+func Test_scanWords(t *testing.T) {
+	for input, want := range map[string]struct {
+		words     []string
+		lastStart int
+	}{
+		``:             {nil, 0},
+		`greet `:       {[]string{"greet"}, 6},
+		`greet 'Mr S`:  {[]string{"greet", "Mr S"}, 6},
+		`greet a\ b`:   {[]string{"greet", "a b"}, 6},
+		`greet "x" y`:  {[]string{"greet", "x", "y"}, 10},
+		`greet --s=f`:  {[]string{"greet", "--s=f"}, 6},
+		`greet "x y" `: {[]string{"greet", "x y"}, 12},
+	} {
+		t.Run(input, func(t *testing.T) {
+			words, lastStart, _, _ := scanWords(input)
+			assert.Equal(t, want.words, words)
+			assert.Equal(t, want.lastStart, lastStart)
+		})
+	}
+}
@@ -1,22 +1,25 @@
 package kongcompletion
 
 import (
-	"errors"
 	"fmt"
 
 	"os"
 	"path/filepath"
 
 	"github.com/alecthomas/kong"
-	"github.com/riywo/loginshell"
 )
 
 // Completion is a kong subcommand that prints out the shell code for
 // initializing tab completion in various shells. It also educates the
 // user what to do with the printed code.
 type Completion struct {
-	Shell string `arg:"" help:"The name of the shell you are using" enum:"bash,zsh,fish," default:""`
-	Code  bool   `short:"c" help:"Generate the initialization code"`
+	Shell   string `arg:"" help:"The name of the shell you are using" enum:"bash,zsh,fish," default:""`
+	Code    bool   `short:"c" help:"Generate the initialization code"`
+	Dir     string `help:"Write the completion files of all shells into this directory tree (e.g. /usr/local)" placeholder:"PREFIX" xor:"static"`
+	BinPath string `help:"The path to the binary that the completions should invoke (defaults to the current binary)" placeholder:"PATH"`
+	Doctor  bool   `help:"Diagnose why tab completion doesn’t work"`
+	Static  bool   `help:"Generate a self-contained script, which only invokes the binary for dynamic values" xor:"static"`
+	Dump    bool   `hidden:"" help:"Print the completion model as JSON"`
 }
 
 // Help is a predefined kong method for printing the help text.
@@ -26,7 +29,13 @@ Displays a command that you need to execute in order activate tab completion for
 
 For permanent activation (i.e. beyond the current shell session), paste the command in your shell’s init file.
 
-If no shell is specified, it tries to detect your current login shell automatically.
+If no shell is specified, it tries to detect the shell you are currently using automatically. You can override the detection by setting the KONG_COMPLETION_SHELL environment variable.
+
+If tab completion doesn’t work, run with --doctor to diagnose the setup.
+
+For tools on slow (e.g. network) filesystems, --static generates a script that contains the commands and flags of this program, so that it only needs to be invoked for completing dynamic values.
+
+For packaging, the completion files of all shells can be written into a directory tree via --dir.
 `
 }
 
@@ -36,6 +45,42 @@ func (c *Completion) Run(ctx *kong.Context) error {
 	if err != nil {
 		return err
 	}
+	if c.BinPath != "" {
+		binInfo.BinPath = c.BinPath
+	}
+	binInfo.Static = c.Static
+
+	// Dump the completion model, if requested.
+	if c.Dump {
+		// The predictors that the app registers aren’t known here, so
+		// problems are only recorded instead of failing.
+		dump, err := Dump(ctx.Kong, WithTolerantMode())
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(ctx.Stdout, string(dump))
+		if err != nil {
+			return err
+		}
+		ctx.Exit(0)
+		return nil
+	}
+
+	// Write static completion files, if requested.
+	if c.Dir != "" {
+		written, err := binInfo.writeCompletionFiles(c.Dir)
+		if err != nil {
+			return err
+		}
+		for _, path := range written {
+			_, err = fmt.Fprintln(ctx.Stdout, path)
+			if err != nil {
+				return err
+			}
+		}
+		ctx.Exit(0)
+		return nil
+	}
 
 	// Determine targeted shell.
 	sh, err := (func() (shell, error) {
@@ -48,17 +93,37 @@ func (c *Completion) Run(ctx *kong.Context) error {
 		return err
 	}
 
+	// Run the diagnosis, if requested.
+	if c.Doctor {
+		_, err = fmt.Fprintf(ctx.Stdout, "Diagnosing tab completion for %s in %s:\n\n", binInfo.BinName, sh.name)
+		if err != nil {
+			return err
+		}
+		failures, err := printDiagnoses(ctx.Stdout, binInfo.diagnose(sh))
+		if err != nil {
+			return err
+		}
+		if failures > 0 {
+			return fmt.Errorf("found %d problem(s) with tab completion", failures)
+		}
+		ctx.Exit(0)
+		return nil
+	}
+
 	// Generate command output.
-	output := (func() string {
+	output, err := (func() (string, error) {
 		if c.Code {
-			return binInfo.fill(sh.initCode)
+			return binInfo.script(ctx.Kong, sh)
 		} else {
 			return "" +
 				"Execute the following command to activate tab completion for " + binInfo.BinName + " in " + sh.name + ":\n\n" +
 				"    " + binInfo.fill(sh.configFileCode) + "\n\n" +
-				"Note that this only takes effect for your current shell session. For permanent activation (beyond the current shell session), you can e.g. paste this command into your " + sh.name + "’s init file, which usually is: " + sh.initFilePath
+				"Note that this only takes effect for your current shell session. For permanent activation (beyond the current shell session), you can e.g. paste this command into your " + sh.name + "’s init file, which usually is: " + sh.initFilePath, nil
 		}
 	})()
+	if err != nil {
+		return err
+	}
 	_, err = fmt.Fprint(ctx.Stdout, output+"\n")
 	if err != nil {
 		return err
@@ -70,20 +135,6 @@ func (c *Completion) Run(ctx *kong.Context) error {
 	return nil
 }
 
-// detectShell tries to determine from the process environment what the user’s
-// login shell is.
-func detectShell() (shell, error) {
-	shellName, err := loginshell.Shell()
-	if err != nil {
-		return shell{}, errors.New("couldn't determine user's shell")
-	}
-	sh, ok := shells[filepath.Base(shellName)]
-	if !ok {
-		return shell{}, errors.New("this shell is not supported (" + shellName + ")")
-	}
-	return sh, nil
-}
-
 // determineBinaryInfo tries to determine information about the current command.
 func determineBinaryInfo(ctx *kong.Context) (templateData, error) {
 	bin, err := os.Executable()
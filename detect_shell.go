@@ -0,0 +1,121 @@
+package kongcompletion
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/riywo/loginshell"
+)
+
+// shellEnvVar can be set by the user to override the shell detection.
+const shellEnvVar = "KONG_COMPLETION_SHELL"
+
+// procDir is the mount point of the proc filesystem.
+var procDir = "/proc"
+
+// knownShells are the names of interactive shells that we recognize in the
+// process tree, regardless of whether they are supported or not.
+var knownShells = []string{"bash", "zsh", "fish", "nu", "ksh", "tcsh", "csh", "pwsh", "elvish", "xonsh"}
+
+// shellVersionEnvVars maps environment variables that are specific to a
+// certain shell to the name of that shell.
+var shellVersionEnvVars = []struct{ envVar, shellName string }{
+	{"FISH_VERSION", "fish"},
+	{"ZSH_VERSION", "zsh"},
+	{"NU_VERSION", "nu"},
+}
+
+// detectShell tries to determine from the process environment what shell
+// the user is currently typing in. It prefers the explicit override, then
+// looks at the parent processes and shell-specific environment variables,
+// and eventually falls back to the user’s login shell.
+func detectShell() (shell, error) {
+	shellName, err := detectShellName()
+	if err != nil {
+		return shell{}, err
+	}
+	return newShellFromString(shellName)
+}
+
+func detectShellName() (string, error) {
+	if shellName := os.Getenv(shellEnvVar); shellName != "" {
+		return shellName, nil
+	}
+	if shellName, ok := shellFromProcessTree(os.Getppid()); ok {
+		return shellName, nil
+	}
+	for _, v := range shellVersionEnvVars {
+		if os.Getenv(v.envVar) != "" {
+			return v.shellName, nil
+		}
+	}
+	shellPath, err := loginshell.Shell()
+	if err != nil {
+		return "", errors.New("couldn't determine user's shell (you can specify it via " + shellEnvVar + ")")
+	}
+	return filepath.Base(shellPath), nil
+}
+
+// shellFromProcessTree walks up the process tree, starting at pid, and returns
+// the name of the first process that is a known shell.
+func shellFromProcessTree(pid int) (string, bool) {
+	// The limit guards against cycles or unexpectedly deep hierarchies.
+	for depth := 0; pid > 1 && depth < 16; depth++ {
+		if shellName, ok := processShellName(pid); ok {
+			return shellName, true
+		}
+		ppid, ok := parentPid(pid)
+		if !ok {
+			return "", false
+		}
+		pid = ppid
+	}
+	return "", false
+}
+
+// processShellName returns the name of the process if it is a known shell.
+func processShellName(pid int) (string, bool) {
+	var candidates []string
+	if comm, err := os.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "comm")); err == nil {
+		candidates = append(candidates, strings.TrimSpace(string(comm)))
+	}
+	if exe, err := os.Readlink(filepath.Join(procDir, strconv.Itoa(pid), "exe")); err == nil {
+		candidates = append(candidates, filepath.Base(exe))
+	}
+	for _, name := range candidates {
+		// Login shells are prefixed with a dash, e.g. `-bash`.
+		name = strings.TrimPrefix(name, "-")
+		for _, knownShell := range knownShells {
+			if name == knownShell {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parentPid reads the parent process id from /proc/<pid>/stat.
+func parentPid(pid int) (int, bool) {
+	stat, err := os.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, false
+	}
+	// The format is `<pid> (<comm>) <state> <ppid> ...`, where <comm> may
+	// contain spaces or parentheses itself.
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 2 {
+		return 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, false
+	}
+	return ppid, true
+}
@@ -0,0 +1,51 @@
+package kongcompletion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellFromProcessTree(t *testing.T) {
+	origProcDir := procDir
+	procDir = t.TempDir()
+	defer func() { procDir = origProcDir }()
+
+	writeProc := func(pid, comm, stat string) {
+		dir := filepath.Join(procDir, pid)
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644))
+	}
+	writeProc("300", "greet", "300 (greet) S 200 300 1 0")
+	writeProc("200", "go (build) x", "200 (go (build) x) S 100 200 1 0")
+	writeProc("100", "-fish", "100 (-fish) S 50 100 1 0")
+	writeProc("50", "bash", "50 (bash) S 1 50 1 0")
+	writeProc("20", "sshd", "20 (sshd) S 1 20 1 0")
+
+	t.Run("finds closest shell", func(t *testing.T) {
+		got, ok := shellFromProcessTree(300)
+		assert.True(t, ok)
+		assert.Equal(t, "fish", got)
+	})
+
+	t.Run("no shell in process tree", func(t *testing.T) {
+		_, ok := shellFromProcessTree(20)
+		assert.False(t, ok)
+	})
+
+	t.Run("missing process", func(t *testing.T) {
+		_, ok := shellFromProcessTree(12345)
+		assert.False(t, ok)
+	})
+}
+
+func TestDetectShellName_override(t *testing.T) {
+	t.Setenv(shellEnvVar, "zsh")
+	got, err := detectShellName()
+	require.NoError(t, err)
+	assert.Equal(t, "zsh", got)
+}
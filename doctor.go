@@ -0,0 +1,177 @@
+package kongcompletion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// doctorTimeout is the time budget for each external process that is
+// spawned during the diagnosis.
+const doctorTimeout = 5 * time.Second
+
+type diagnosisStatus int
+
+const (
+	diagnosisOk diagnosisStatus = iota
+	diagnosisWarning
+	diagnosisFailure
+)
+
+// diagnosis is the outcome of a single check.
+type diagnosis struct {
+	status  diagnosisStatus
+	message string
+	hint    string // What the user can do about it (only for non-ok outcomes).
+}
+
+// diagnose checks the prerequisites for tab completion to work.
+func (bi templateData) diagnose(sh shell) []diagnosis {
+	return []diagnosis{
+		bi.diagnoseBinPath(),
+		bi.diagnoseLookPath(),
+		bi.diagnoseInitFile(sh),
+		bi.diagnoseFramework(sh),
+		bi.diagnoseRoundTrip(),
+	}
+}
+
+func (bi templateData) diagnoseBinPath() diagnosis {
+	info, err := os.Stat(bi.BinPath)
+	if err != nil {
+		return diagnosis{diagnosisFailure, "The binary doesn’t exist at " + bi.BinPath,
+			"The completions invoke this path, so make sure to (re-)generate the shell code after installing or moving the binary."}
+	}
+	if info.IsDir() || info.Mode()&0o111 == 0 {
+		return diagnosis{diagnosisFailure, bi.BinPath + " is not an executable file", ""}
+	}
+	return diagnosis{diagnosisOk, "The binary exists at " + bi.BinPath, ""}
+}
+
+func (bi templateData) diagnoseLookPath() diagnosis {
+	found, err := exec.LookPath(bi.BinName)
+	if err != nil {
+		return diagnosis{diagnosisFailure, bi.BinName + " is not found on your $PATH",
+			"Completions only work for binaries that are invoked via $PATH, so add " + filepath.Dir(bi.BinPath) + " to your $PATH."}
+	}
+	if !sameFile(found, bi.BinPath) {
+		return diagnosis{diagnosisWarning, bi.BinName + " resolves to " + found + " on your $PATH",
+			"This is not the binary that provides the completions (" + bi.BinPath + "), so the completions might be out of sync."}
+	}
+	return diagnosis{diagnosisOk, bi.BinName + " is found on your $PATH", ""}
+}
+
+func (bi templateData) diagnoseInitFile(sh shell) diagnosis {
+	activation := bi.fill(sh.configFileCode)
+	path := sh.initFilePath
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return diagnosis{diagnosisWarning, "Couldn’t locate the init file " + sh.initFilePath, ""}
+		}
+		path = filepath.Join(home, rest)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return diagnosis{diagnosisWarning, "Couldn’t read the init file " + sh.initFilePath,
+			"For permanent activation, add this line to it: " + activation}
+	}
+	if !strings.Contains(string(content), bi.BinName+" "+bi.SubCmdName) {
+		return diagnosis{diagnosisWarning, "The init file " + sh.initFilePath + " doesn’t activate the completions",
+			"For permanent activation, add this line to it: " + activation}
+	}
+	return diagnosis{diagnosisOk, "The init file " + sh.initFilePath + " activates the completions", ""}
+}
+
+func (bi templateData) diagnoseFramework(sh shell) diagnosis {
+	var check, framework string
+	switch sh.name {
+	case bash.name:
+		framework = "bash-completion"
+		check = "declare -F _init_completion || declare -F _comp_initialize"
+	case zsh.name:
+		framework = "compinit"
+		check = "whence compdef"
+	default:
+		return diagnosis{diagnosisOk, sh.name + " doesn’t need a completion framework", ""}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	err := exec.CommandContext(ctx, sh.name, "-i", "-c", check).Run()
+	if err != nil {
+		if sh.name == bash.name {
+			return diagnosis{diagnosisWarning, framework + " doesn’t seem to be loaded in interactive " + sh.name + " sessions",
+				"This is only relevant for completion files that are installed into the system’s completion directories."}
+		}
+		return diagnosis{diagnosisFailure, framework + " doesn’t seem to be loaded in interactive " + sh.name + " sessions",
+			"Add `autoload -U compinit && compinit` to " + sh.initFilePath + "."}
+	}
+	return diagnosis{diagnosisOk, framework + " is loaded in interactive " + sh.name + " sessions", ""}
+}
+
+// diagnoseRoundTrip invokes the binary the same way as the shell does, i.e.
+// with the command line in the environment, which only Register looks at,
+// rather than as arguments. An app that doesn’t call Register thus runs as if
+// invoked without arguments, which usually fails with its usage.
+func (bi templateData) diagnoseRoundTrip() diagnosis {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	line := bi.BinName + " "
+	cmd := exec.CommandContext(ctx, bi.BinPath)
+	cmd.Env = append(os.Environ(), envLine+"="+line, envPoint+"="+strconv.Itoa(len(line)), bi.ProtocolEnv())
+	out, err := cmd.Output()
+	if err != nil {
+		return diagnosis{diagnosisFailure, "The binary failed to respond to a completion request: " + err.Error(),
+			"Make sure that kongcompletion.Register is invoked before the arguments are parsed."}
+	}
+	candidates := strings.Fields(string(out))
+	if len(candidates) == 0 {
+		return diagnosis{diagnosisWarning, "The binary didn’t suggest any completions for `" + line + "`", ""}
+	}
+	return diagnosis{diagnosisOk, fmt.Sprintf("The binary responded to a completion request with %d suggestion(s)", len(candidates)), ""}
+}
+
+// printDiagnoses prints the outcomes, and returns the number of failures.
+func printDiagnoses(w io.Writer, diagnoses []diagnosis) (int, error) {
+	failures := 0
+	for _, d := range diagnoses {
+		symbol := "✓"
+		switch d.status {
+		case diagnosisWarning:
+			symbol = "!"
+		case diagnosisFailure:
+			symbol = "✗"
+			failures++
+		}
+		_, err := fmt.Fprintf(w, "%s %s\n", symbol, d.message)
+		if err != nil {
+			return failures, err
+		}
+		if d.status != diagnosisOk && d.hint != "" {
+			_, err = fmt.Fprintf(w, "  → %s\n", d.hint)
+			if err != nil {
+				return failures, err
+			}
+		}
+	}
+	return failures, nil
+}
+
+// sameFile reports whether both paths point to the same file.
+func sameFile(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
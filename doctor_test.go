@@ -0,0 +1,112 @@
+package kongcompletion
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBinary writes an executable shell script to dir.
+func fakeBinary(t *testing.T, dir string, name string, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755))
+	return path
+}
+
+func TestDiagnoseInitFile(t *testing.T) {
+	bi := templateData{BinName: "greet", BinPath: "/usr/bin/greet", SubCmdName: "completion"}
+	for name, td := range map[string]struct {
+		content *string
+		want    diagnosisStatus
+	}{
+		"missing": {content: nil, want: diagnosisWarning},
+		"stale":   {content: ptr("alias g=greet\n"), want: diagnosisWarning},
+		"ok":      {content: ptr("source <(greet completion -c bash)\n"), want: diagnosisOk},
+	} {
+		t.Run(name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			if td.content != nil {
+				require.NoError(t, os.WriteFile(filepath.Join(home, ".bashrc"), []byte(*td.content), 0o644))
+			}
+			d := bi.diagnoseInitFile(bash)
+			assert.Equal(t, td.want, d.status, d.message)
+			if td.want != diagnosisOk {
+				assert.Contains(t, d.hint, bi.fill(bash.configFileCode))
+			}
+		})
+	}
+}
+
+func TestDiagnoseLookPath(t *testing.T) {
+	installed := fakeBinary(t, t.TempDir(), "greet", "exit 0\n")
+	other := t.TempDir()
+	fakeBinary(t, other, "greet", "exit 0\n")
+	bi := templateData{BinName: "greet", BinPath: installed}
+
+	for name, td := range map[string]struct {
+		path string
+		want diagnosisStatus
+	}{
+		"missing": {path: t.TempDir(), want: diagnosisFailure},
+		"stale":   {path: other, want: diagnosisWarning},
+		"ok":      {path: filepath.Dir(installed), want: diagnosisOk},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("PATH", td.path)
+			d := bi.diagnoseLookPath()
+			assert.Equal(t, td.want, d.status, d.message)
+		})
+	}
+}
+
+func TestDiagnoseRoundTrip(t *testing.T) {
+	for name, td := range map[string]struct {
+		script string
+		want   diagnosisStatus
+	}{
+		"not registered": {
+			script: "echo 'greet: error: expected one of \"hello\", \"wave\"' >&2\nexit 80\n",
+			want:   diagnosisFailure,
+		},
+		"no suggestions": {
+			script: "exit 0\n",
+			want:   diagnosisWarning,
+		},
+		"ok": {
+			script: `[ "$COMP_LINE" = "greet " ] && [ "$KONG_COMPLETION_PROTOCOL" = 1 ] && printf 'hello\nwave\n'
+`,
+			want: diagnosisOk,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			bi := templateData{BinName: "greet", BinPath: fakeBinary(t, dir, "greet", `[ $# -eq 0 ] || touch "$(dirname "$0")/ran"
+`+td.script)}
+			d := bi.diagnoseRoundTrip()
+			assert.Equal(t, td.want, d.status, d.message)
+			assert.NoFileExists(t, filepath.Join(dir, "ran"), "must not pass any arguments to the app")
+		})
+	}
+}
+
+func TestPrintDiagnoses(t *testing.T) {
+	var out bytes.Buffer
+	failures, err := printDiagnoses(&out, []diagnosis{
+		{diagnosisOk, "fine", "ignored"},
+		{diagnosisWarning, "odd", "try this"},
+		{diagnosisFailure, "broken", ""},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures)
+	assert.Equal(t, "✓ fine\n! odd\n  → try this\n✗ broken\n", out.String())
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
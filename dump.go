@@ -0,0 +1,128 @@
+package kongcompletion
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/alecthomas/kong"
+)
+
+// dumpCommand is the JSON representation of a (sub)command in Dump.
+type dumpCommand struct {
+	Name        string        `json:"name"`
+	Aliases     []string      `json:"aliases,omitempty"`
+	Help        string        `json:"help,omitempty"`
+	Hidden      bool          `json:"hidden,omitempty"`
+	Enabled     bool          `json:"enabled"`
+	Flags       []dumpFlag    `json:"flags,omitempty"`
+	Positionals []dumpValue   `json:"positionals,omitempty"`
+	Commands    []dumpCommand `json:"commands,omitempty"`
+}
+
+type dumpFlag struct {
+	Names   []string `json:"names"`
+	Kind    string   `json:"kind"` // Either "bool" or "arg", depending on whether the flag takes a value.
+	Hidden  bool     `json:"hidden,omitempty"`
+	Enabled bool     `json:"enabled"`
+	dumpValue
+}
+
+type dumpValue struct {
+	Name       string   `json:"name,omitempty"`
+	Predictor  string   `json:"predictor"`
+	Command    []string `json:"command,omitempty"` // The external command of a `(command)` predictor.
+	Values     []string `json:"values,omitempty"`  // The values of an `(enum)` predictor.
+	Cumulative bool     `json:"cumulative,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Dump returns the completion tree of a kong app as JSON. It lists all
+// commands, flags and positional arguments, including the ones that
+// completion is disabled for, along with the predictors that complete their
+// values. Predictors are identified by their names, or by one of `(command)`,
+// `(enum)`, `(nothing)` and `(anything)`. That way, the completion behaviour
+// can be snapshotted in tests, or be processed by other tooling.
+//
+// Unless in tolerant mode, Dump fails if a predictor is misconfigured. Unlike
+// Validate, it doesn’t check whether the named predictors are registered.
+func Dump(parser *kong.Kong, opt ...Option) ([]byte, error) {
+	if parser == nil || parser.Model == nil {
+		return nil, fmt.Errorf("no kong model given")
+	}
+	opts := buildOptions(opt...)
+	root, err := dumpNode(parser.Model.Node, opts, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func dumpNode(node *kong.Node, opts *options, vars kong.Vars, enabled bool) (dumpCommand, error) {
+	vars = vars.CloneWith(node.Vars())
+	cmd := dumpCommand{
+		Name:    node.Name,
+		Aliases: node.Aliases,
+		Help:    node.Help,
+		Hidden:  node.Hidden,
+		Enabled: enabled,
+	}
+	for _, flag := range node.Flags {
+		if flag == nil {
+			continue
+		}
+		value, err := dumpValueOf(flag.Value, opts, vars, node.FullPath()+" --"+flag.Name)
+		if err != nil {
+			return cmd, err
+		}
+		value.Name = ""
+		kind := "arg"
+		if flag.Value.IsBool() {
+			kind = "bool"
+		}
+		cmd.Flags = append(cmd.Flags, dumpFlag{
+			Names:     flagNamesWithHyphens(flag),
+			Kind:      kind,
+			Hidden:    flag.Hidden,
+			Enabled:   enabled && isCompletionEnabled(flag.Tag),
+			dumpValue: value,
+		})
+	}
+	for _, arg := range node.Positional {
+		value, err := dumpValueOf(arg, opts, vars, node.FullPath()+" <"+arg.Name+">")
+		if err != nil {
+			return cmd, err
+		}
+		cmd.Positionals = append(cmd.Positionals, value)
+	}
+	for _, child := range node.Children {
+		if child == nil {
+			continue
+		}
+		childCmd, err := dumpNode(child, opts, vars, enabled && isCompletionEnabled(child.Tag))
+		if err != nil {
+			return cmd, err
+		}
+		cmd.Commands = append(cmd.Commands, childCmd)
+	}
+	return cmd, nil
+}
+
+// dumpValueOf describes how a value is completed. Misconfigured predictors
+// are reported with the path of the value, or recorded in tolerant mode.
+func dumpValueOf(value *kong.Value, opts *options, vars kong.Vars, path string) (dumpValue, error) {
+	v := dumpValue{
+		Name:       value.Name,
+		Predictor:  predictorName(value, vars),
+		Cumulative: value.IsCumulative(),
+	}
+	model, err := modelValueOf(value, vars)
+	if err != nil {
+		if !opts.tolerant {
+			return v, fmt.Errorf("%s: %w", path, err)
+		}
+		v.Error = err.Error()
+	}
+	v.Command = model.Command
+	v.Values = model.Values
+	return v, nil
+}
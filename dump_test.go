@@ -0,0 +1,86 @@
+package kongcompletion
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDump(t *testing.T) {
+	var cli struct {
+		staticApp
+		Debug struct {
+			Level int `completion-enabled:"false"`
+		} `cmd:"" completion-enabled:"false"`
+	}
+	got, err := Dump(kong.Must(&cli, kong.Name("app")))
+	require.NoError(t, err)
+
+	var root dumpCommand
+	require.NoError(t, json.Unmarshal(got, &root))
+	assert.Equal(t, "app", root.Name)
+	assert.True(t, root.Enabled)
+	assert.Equal(t, dumpFlag{
+		Names:     []string{"--verbose", "-v"},
+		Kind:      "bool",
+		Enabled:   true,
+		dumpValue: dumpValue{Predictor: "(nothing)"},
+	}, root.Flags[1])
+	require.Len(t, root.Commands, 4)
+
+	open := root.Commands[0]
+	assert.Equal(t, []string{"o"}, open.Aliases)
+	assert.Equal(t, "arg", open.Flags[0].Kind)
+	assert.Equal(t, []dumpValue{{Name: "files", Predictor: "(anything)", Cumulative: true}}, open.Positionals)
+
+	tag := root.Commands[1]
+	assert.Equal(t, []dumpValue{
+		{Name: "name", Predictor: "(command)", Command: []string{"git", "tag", "--list"}},
+		{Name: "color", Predictor: "(enum)", Values: []string{"red", "green"}},
+		{Name: "user", Predictor: "users"},
+	}, tag.Positionals)
+
+	secret := root.Commands[2]
+	assert.True(t, secret.Hidden)
+	assert.False(t, secret.Enabled)
+
+	// Disabled commands disable their flags too.
+	debug := root.Commands[3]
+	assert.False(t, debug.Enabled)
+	assert.False(t, debug.Flags[0].Enabled)
+}
+
+func TestDumpMisconfiguredPredictor(t *testing.T) {
+	var cli struct {
+		Name string `completion-predictor-cmd:"'oops"`
+	}
+	parser := kong.Must(&cli, kong.Name("app"))
+
+	_, err := Dump(parser)
+	assert.ErrorContains(t, err, "app --name: parsing predictor command")
+
+	got, err := Dump(parser, WithTolerantMode())
+	require.NoError(t, err)
+	var root dumpCommand
+	require.NoError(t, json.Unmarshal(got, &root))
+	assert.Contains(t, root.Flags[1].Error, "parsing predictor command")
+}
+
+func TestCompletionDump(t *testing.T) {
+	var cli struct {
+		Completion Completion `cmd:""`
+	}
+	var stdout bytes.Buffer
+	parser := kong.Must(&cli, kong.Name("app"), kong.Writers(&stdout, &stdout), kong.Exit(func(int) {}))
+	ctx, err := parser.Parse([]string{"completion", "--dump"})
+	require.NoError(t, err)
+	require.NoError(t, ctx.Run())
+
+	want, err := Dump(parser)
+	require.NoError(t, err)
+	assert.Equal(t, string(want)+"\n", stdout.String())
+}
@@ -0,0 +1,219 @@
+package kongcompletion
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// e2eAppEnvVar makes the test binary act as the e2eApp, so that the shells
+// can invoke it for computing completions.
+const e2eAppEnvVar = "KONG_COMPLETION_E2E_APP"
+
+type e2eApp struct {
+	Loud  bool `short:"l"`
+	Greet struct {
+		Style string `enum:"formal,casual" default:"casual"`
+		Name  string `arg:"" completion-predictor:"names"`
+	} `cmd:""`
+	Wave struct{} `cmd:"" aliases:"hi"`
+}
+
+var e2eOptions = []Option{
+	WithPredictor("names", complete.PredictSet("Ben", "Liz")),
+}
+
+func newE2EParser() *kong.Kong {
+	return kong.Must(&e2eApp{}, kong.Name("greet"))
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv(e2eAppEnvVar) != "" {
+		Register(newE2EParser(), e2eOptions...)
+		os.Exit(1) // Register is supposed to exit.
+	}
+	os.Exit(m.Run())
+}
+
+// e2eDrivers are shell scripts that source the init script (passed as $SCRIPT)
+// and print the candidates for the command line (passed as $LINE).
+var e2eDrivers = map[string][]string{
+	// Obtain the registered command or function from `complete -p`, and
+	// invoke it the same way as bash does. Like bash, split the words at the
+	// characters of COMP_WORDBREAKS, which e.g. makes `=` a word of its own.
+	"bash": {"bash", "--norc", "--noprofile", "-c", `
+		source "$SCRIPT" || exit 3
+		spec=$(complete -p greet) || exit 4
+		complete() { while [ $# -gt 0 ]; do case "$1" in -C | -F) printf '%s %s' "$1" "$2" && return ;; esac; shift; done; }
+		cmd=$(eval "$spec")
+		export COMP_LINE="$LINE" COMP_POINT=${#LINE}
+		case "$cmd" in
+		-C*) eval "${cmd#-C } greet '' ''" ;;
+		-F*)
+			COMP_WORDS=() word=""
+			for ((i = 0; i < ${#LINE}; i++)); do
+				c="${LINE:i:1}"
+				if [[ "$c" == [[:space:]] ]]; then
+					[[ -n "$word" ]] && COMP_WORDS+=("$word")
+					word=""
+				elif [[ "$COMP_WORDBREAKS" == *"$c"* ]]; then
+					[[ -n "$word" && "$word" != "$c" ]] && COMP_WORDS+=("$word") && word=""
+					word+="$c"
+				else
+					[[ -n "$word" && "$COMP_WORDBREAKS" == *"${word: -1}"* ]] && COMP_WORDS+=("$word") && word=""
+					word+="$c"
+				fi
+			done
+			COMP_WORDS+=("$word")
+			COMP_CWORD=$((${#COMP_WORDS[@]} - 1))
+			"${cmd#-F }" greet "${COMP_WORDS[COMP_CWORD]}" "${COMP_WORDS[COMP_CWORD - 1]}"
+			printf '%s\n' "${COMPREPLY[@]}"
+			;;
+		esac
+	`},
+	// Outside of a terminal, zsh can’t run completion widgets, so set up
+	// their context (words, CURRENT, PREFIX) by hand, and invoke the
+	// registered completion. Like the builtins, compset moves a matching part
+	// of PREFIX to IPREFIX, and compadd only keeps the candidates that start
+	// with PREFIX, which it prints instead of adding them.
+	"zsh": {"zsh", "-f", "-c", `
+		autoload -U compinit && compinit -u
+		source "$SCRIPT" || exit 3
+		(( ${+_comps[greet]} )) || exit 4
+		compadd() {
+			local arrays=0 all=0 w
+			local -a added
+			while (( $# )); do
+				case "$1" in
+				-a) arrays=1 ;;
+				-U) all=1 ;;
+				-[PSpsiIJVXxWFrRMDOAEo]) shift ;;
+				-) shift; break ;;
+				-*) ;;
+				*) break ;;
+				esac
+				shift
+			done
+			for w in "$@"; do
+				if (( arrays )); then added+=("${(@P)w}"); else added+=("$w"); fi
+			done
+			for w in "${added[@]}"; do
+				(( all )) || [[ "$w" == "$PREFIX"* ]] && print -r -- "$w"
+			done
+			return 0
+		}
+		compset() {
+			[[ "$1" == -P ]] || return 1
+			local match=${(M)PREFIX##${~2}}
+			[[ -n "$match" ]] || return 1
+			IPREFIX+=$match
+			PREFIX=${PREFIX#"$match"}
+		}
+		_default() { return 1 }
+		words=(${(z)LINE})
+		[[ "$LINE" == *" " ]] && words+=("")
+		CURRENT=${#words} PREFIX="${words[-1]}" IPREFIX="" QIPREFIX=""
+		eval "$_comps[greet]" || true
+	`},
+	"fish": {"fish", "--no-config", "-c", `
+		source $SCRIPT; or exit 3
+		complete -C $LINE | string replace -r '\t.*' ''
+	`},
+}
+
+func TestShellScripts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end tests in short mode")
+	}
+	testBin, err := os.Executable()
+	require.NoError(t, err)
+
+	// The directory name contains a space to check that paths are quoted properly.
+	binDir := filepath.Join(t.TempDir(), "my bin")
+	require.NoError(t, os.Mkdir(binDir, 0o755))
+	binPath := filepath.Join(binDir, "greet")
+	require.NoError(t, os.Symlink(testBin, binPath))
+
+	lines := []string{
+		"greet ",
+		"greet gr",
+		"greet -",
+		"greet greet ",
+		"greet greet L",
+		"greet greet --style ",
+		"greet greet --style=",
+		"greet greet --style=f",
+		"greet --loud hi ",
+	}
+
+	modes := []string{"dynamic", "static", "files"}
+	for shellName, driver := range e2eDrivers {
+		for _, mode := range modes {
+			t.Run(shellName+"/"+mode, func(t *testing.T) {
+				if _, err := exec.LookPath(driver[0]); err != nil {
+					if os.Getenv("CI") != "" {
+						t.Fatalf("%s is not installed", shellName)
+					}
+					t.Skipf("%s is not installed", shellName)
+				}
+				scriptPath := e2eScript(t, shellName, mode, binPath)
+
+				for _, line := range lines {
+					t.Run(line, func(t *testing.T) {
+						cmd := exec.Command(driver[0], driver[1:]...)
+						cmd.Env = append(os.Environ(), "SCRIPT="+scriptPath, "LINE="+line, e2eAppEnvVar+"=1")
+						out, err := cmd.Output()
+						require.NoError(t, err, "running %s: %s", shellName, out)
+
+						want, err := Complete(newE2EParser(), line, len(line), e2eOptions...)
+						require.NoError(t, err)
+						// Some shells print the values of `--flag=value` along
+						// with the flag, since that is the word they complete.
+						var got []string
+						for _, field := range strings.Fields(string(out)) {
+							got = append(got, field[strings.LastIndex(field, "=")+1:])
+						}
+						assert.ElementsMatch(t, candidateValues(want), got)
+					})
+				}
+			})
+		}
+	}
+}
+
+// e2eScript writes the code that activates the completions of the shell in
+// the given mode, and returns the path of the script that loads it.
+func e2eScript(t *testing.T, shellName string, mode string, binPath string) string {
+	dir := t.TempDir()
+	var script string
+	switch mode {
+	case "files":
+		_, err := WriteCompletionFiles(dir, "greet", binPath)
+		require.NoError(t, err)
+		data := templateData{BinName: "greet"}
+		path := filepath.Join(dir, filepath.FromSlash(data.fill(shells[shellName].completionFilePath)))
+		if shellName != zsh.name {
+			return path
+		}
+		// zsh picks up completion files from $fpath when initialized.
+		script = "fpath=(" + shellQuote(filepath.Dir(path)) + " $fpath) && compinit -u -D\n"
+	default:
+		opts := []ScriptOption{WithBinPath(binPath)}
+		if mode == "static" {
+			opts = append(opts, WithStatic())
+		}
+		var err error
+		script, err = Script(newE2EParser(), shellName, opts...)
+		require.NoError(t, err)
+	}
+	path := filepath.Join(dir, "init")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o644))
+	return path
+}
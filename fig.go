@@ -0,0 +1,163 @@
+package kongcompletion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// The types below describe the completion spec format of Fig, which is also
+// understood by e.g. inshellisense and Amazon Q. See
+// https://fig.io/docs/reference/subcommand for reference.
+
+type figSubcommand struct {
+	Name        any             `json:"name"` // Either a string or a list of strings.
+	Description string          `json:"description,omitempty"`
+	Subcommands []figSubcommand `json:"subcommands,omitempty"`
+	Options     []figOption     `json:"options,omitempty"`
+	Args        []figArg        `json:"args,omitempty"`
+}
+
+type figOption struct {
+	Name         []string `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	Args         *figArg  `json:"args,omitempty"`
+	IsRequired   bool     `json:"isRequired,omitempty"`
+	IsPersistent bool     `json:"isPersistent,omitempty"`
+	IsRepeatable bool     `json:"isRepeatable,omitempty"`
+}
+
+type figArg struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+	Template    string   `json:"template,omitempty"`
+	Generators  any      `json:"generators,omitempty"`
+	IsVariadic  bool     `json:"isVariadic,omitempty"`
+	IsOptional  bool     `json:"isOptional,omitempty"`
+}
+
+type figGenerator struct {
+	Script  []string `json:"script"`
+	SplitOn string   `json:"splitOn"`
+}
+
+// figCompleteGenerator is a placeholder for the generator that calls back
+// into the binary, which can’t be expressed in JSON.
+const figCompleteGenerator = "\x00completeGenerator"
+
+const figPrologue = `// Completion spec for %s, generated from its command-line model.
+const completeGenerator: Fig.Generator = {
+  script: (tokens) => [%s, "` + completeCmd + `", ...tokens.slice(1)],
+  postProcess: (out) =>
+    out
+      .split("\n")
+      .filter((line) => line !== "")
+      .map((line) => {
+        const [name, description] = line.split("\t");
+        return { name, description };
+      }),
+};
+
+const completionSpec: Fig.Spec = `
+
+const figEpilogue = `;
+
+export default completionSpec;
+`
+
+// FigSpec returns a completion spec of a kong app in the format of Fig, which
+// is written in TypeScript. Values with a completion-predictor are completed
+// by invoking the binary, so Register must be called by the app.
+func FigSpec(parser *kong.Kong, opt ...ScriptOption) (string, error) {
+	data := buildTemplateData(parser, opt...)
+	root, err := buildModel(parser)
+	if err != nil {
+		return "", err
+	}
+	spec := figCommand(root)
+	spec.Name = data.BinName
+
+	b := &bytes.Buffer{}
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(spec)
+	if err != nil {
+		return "", err
+	}
+	binPath, err := json.Marshal(data.BinPath)
+	if err != nil {
+		return "", err
+	}
+	body := strings.ReplaceAll(strings.TrimSuffix(b.String(), "\n"), `"\u0000completeGenerator"`, "completeGenerator")
+	return fmt.Sprintf(figPrologue, data.BinName, binPath) + body + figEpilogue, nil
+}
+
+// WriteFigSpec writes the output of FigSpec to w.
+func WriteFigSpec(w io.Writer, parser *kong.Kong, opt ...ScriptOption) error {
+	spec, err := FigSpec(parser, opt...)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, spec)
+	return err
+}
+
+func figCommand(cmd *modelCommand) figSubcommand {
+	sub := figSubcommand{
+		Name:        append([]string{cmd.Name}, cmd.Aliases...),
+		Description: cmd.Help,
+	}
+	for _, flag := range cmd.Flags {
+		option := figOption{
+			Name:        flag.flagNames(),
+			Description: flag.Help,
+			IsRequired:  flag.Value.Required,
+			// The flags of a kong command apply to its subcommands as well.
+			IsPersistent: len(cmd.Commands) > 0,
+			IsRepeatable: flag.Value.Cumulative,
+		}
+		if !flag.Bool {
+			arg := figValue(flag.Value)
+			arg.Description = ""
+			arg.IsOptional = false
+			arg.IsVariadic = false
+			option.Args = &arg
+		}
+		sub.Options = append(sub.Options, option)
+	}
+	for _, arg := range cmd.Args {
+		sub.Args = append(sub.Args, figValue(arg))
+	}
+	for _, child := range cmd.Commands {
+		sub.Subcommands = append(sub.Subcommands, figCommand(child))
+	}
+	return sub
+}
+
+func figValue(v modelValue) figArg {
+	arg := figArg{
+		Name:        v.Name,
+		Description: v.Help,
+		IsVariadic:  v.Cumulative,
+		IsOptional:  !v.Required,
+	}
+	switch {
+	case v.Predictor != "":
+		arg.Generators = figCompleteGenerator
+	case v.Command != nil:
+		arg.Generators = figGenerator{Script: v.Command, SplitOn: "\n"}
+	case v.Values != nil:
+		arg.Suggestions = v.Values
+	case v.Hint == hintFile:
+		arg.Template = "filepaths"
+	case v.Hint == hintDir:
+		arg.Template = "folders"
+	}
+	return arg
+}
@@ -0,0 +1,57 @@
+package kongcompletion
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFigSpec(t *testing.T) {
+	parser := kong.Must(&staticApp{}, kong.Name("app"))
+	got, err := FigSpec(parser, WithBinPath("/opt/app"))
+	require.NoError(t, err)
+	assert.Contains(t, got, `script: (tokens) => ["/opt/app", "__complete", ...tokens.slice(1)],`)
+
+	// The spec object is JSON, except for the references to the generator.
+	prologue, body, ok := strings.Cut(got, "const completionSpec: Fig.Spec = ")
+	require.True(t, ok)
+	assert.Contains(t, prologue, "const completeGenerator: Fig.Generator = {")
+	body, ok = strings.CutSuffix(body, ";\n\nexport default completionSpec;\n")
+	require.True(t, ok)
+	body = strings.ReplaceAll(body, ": completeGenerator", `: "completeGenerator"`)
+	var spec figSubcommand
+	require.NoError(t, json.Unmarshal([]byte(body), &spec))
+
+	assert.Equal(t, "app", spec.Name)
+	assert.Equal(t, []figOption{
+		{Name: []string{"--help", "-h"}, Description: "Show context-sensitive help.", IsPersistent: true},
+		{Name: []string{"--verbose", "-v"}, IsPersistent: true},
+	}, spec.Options)
+	require.Len(t, spec.Subcommands, 2)
+
+	open := spec.Subcommands[0]
+	assert.Equal(t, []any{"open", "o"}, open.Name)
+	assert.Equal(t, "Open some files.", open.Description)
+	assert.Equal(t, []figOption{
+		{Name: []string{"--config"}, Description: "The config file.", Args: &figArg{Name: "config", Template: "filepaths"}},
+		{Name: []string{"--into"}, Args: &figArg{Name: "into", Template: "folders"}},
+		{Name: []string{"--mode"}, Args: &figArg{Name: "mode", Suggestions: []string{"read", "write"}}},
+	}, open.Options)
+	assert.Equal(t, []figArg{{Name: "files", Template: "filepaths", IsVariadic: true}}, open.Args)
+
+	tag := spec.Subcommands[1]
+	assert.Equal(t, []figArg{
+		{Name: "name", Generators: map[string]any{"script": []any{"git", "tag", "--list"}, "splitOn": "\n"}},
+		{Name: "color", Suggestions: []string{"red", "green"}, IsOptional: true},
+		{Name: "user", Generators: "completeGenerator", IsOptional: true},
+	}, tag.Args)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteFigSpec(&buf, parser, WithBinPath("/opt/app")))
+	assert.Equal(t, got, buf.String())
+}
@@ -0,0 +1,48 @@
+package kongcompletion
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// WriteCompletionFiles writes the static completion files of all supported
+// shells into the directory tree at root, which is a prefix such as /usr or
+// /usr/local. The files are placed where the shells look for completions by
+// default, e.g. `<root>/share/bash-completion/completions/<binName>`.
+//
+// binPath is the location of the binary at which it will be installed, which
+// is not necessarily where it resides at the time of writing the files.
+// It returns the paths of the written files.
+func WriteCompletionFiles(root string, binName string, binPath string) ([]string, error) {
+	data := templateData{
+		BinName:         binName,
+		BinPath:         binPath,
+		UseShellDefault: true,
+	}
+	return data.writeCompletionFiles(root)
+}
+
+func (bi templateData) writeCompletionFiles(root string) ([]string, error) {
+	var written []string
+	for _, name := range slices.Sorted(maps.Keys(shells)) {
+		sh := shells[name]
+		content := sh.completionFile
+		if content == nil {
+			content = sh.initCode
+		}
+		path := filepath.Join(root, filepath.FromSlash(bi.fill(sh.completionFilePath)))
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			return written, fmt.Errorf("couldn't create directory for %s completion file: %w", sh.name, err)
+		}
+		err = os.WriteFile(path, []byte(bi.fill(content)+"\n"), 0o644)
+		if err != nil {
+			return written, fmt.Errorf("couldn't write %s completion file: %w", sh.name, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
@@ -0,0 +1,42 @@
+package kongcompletion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCompletionFiles(t *testing.T) {
+	root := t.TempDir()
+	written, err := WriteCompletionFiles(root, "greet", "/usr/bin/greet")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join(root, "share", "bash-completion", "completions", "greet"),
+		filepath.Join(root, "share", "fish", "vendor_completions.d", "greet.fish"),
+		filepath.Join(root, "share", "zsh", "site-functions", "_greet"),
+	}, written)
+
+	bashFile, err := os.ReadFile(written[0])
+	require.NoError(t, err)
+	assert.Equal(t, "complete -o default -o bashdefault -C 'KONG_COMPLETION_PROTOCOL=1 /usr/bin/greet' greet\n", string(bashFile))
+
+	zshFile, err := os.ReadFile(written[2])
+	require.NoError(t, err)
+	assert.Contains(t, string(zshFile), "#compdef greet\n")
+	assert.Contains(t, string(zshFile), "/usr/bin/greet")
+	assert.Contains(t, string(zshFile), "compset -P '*='\n", "completes the values of --flag=value")
+}
+
+func TestCompletionDirRejectsStatic(t *testing.T) {
+	var cli struct {
+		Completion Completion `cmd:""`
+	}
+	parser := kong.Must(&cli, kong.Name("app"))
+	_, err := parser.Parse([]string{"completion", "--dir", t.TempDir(), "--static"})
+	assert.EqualError(t, err, "--dir and --static can't be used together")
+}
@@ -0,0 +1,186 @@
+package kongcompletion
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+)
+
+// WithPredictorFunc use the named predictor function, whose parameters are
+// resolved from the kong bindings of the parser (see kong.Bind, kong.BindTo,
+// kong.BindToProvider), like for the Run methods of commands. In addition,
+// the following values can be bound:
+//   - complete.Args, which is the command line that is being completed
+//   - context.Context, which is cancelled according to WithTimeout
+//   - *kong.Context, which reflects the command line that is being completed
+//   - the `Provide*` methods of the commands on the command line, and
+//     pointers to the command structs themselves
+//
+// The function must return either []string or ([]string, error). It panics
+// if fn has a different signature.
+func WithPredictorFunc(name string, fn any) Option {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		panic(fmt.Sprintf("predictor %q: %T is not a function", name, fn))
+	}
+	ft := fv.Type()
+	stringsType := reflect.TypeOf([]string(nil))
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	if !(ft.NumOut() == 1 && ft.Out(0) == stringsType) && !(ft.NumOut() == 2 && ft.Out(0) == stringsType && ft.Out(1) == errorType) {
+		panic(fmt.Sprintf("predictor %q: %s must return []string or ([]string, error)", name, ft))
+	}
+	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	takesContext := false
+	for i := 0; i < ft.NumIn(); i++ {
+		takesContext = takesContext || ft.In(i) == contextType
+	}
+	return func(o *options) {
+		p := &funcPredictor{
+			opts: o,
+			name: name,
+			fn:   fv,
+		}
+		if takesContext {
+			WithPredictor(name, contextFuncPredictor{p})(o)
+			return
+		}
+		// Otherwise, the function can’t honour the deadline, so it must be
+		// treated like any other plain predictor.
+		WithPredictor(name, p)(o)
+	}
+}
+
+// funcPredictor invokes a function with parameters resolved from bindings.
+type funcPredictor struct {
+	opts *options
+	name string
+	fn   reflect.Value
+}
+
+// Predict implements complete.Predictor
+func (p *funcPredictor) Predict(a complete.Args) []string {
+	return p.predict(context.Background(), a)
+}
+
+func (p *funcPredictor) forRequest(ctx context.Context) complete.Predictor {
+	return complete.PredictFunc(func(a complete.Args) []string {
+		return p.predict(ctx, a)
+	})
+}
+
+func (p *funcPredictor) predict(ctx context.Context, a complete.Args) []string {
+	result, err := p.call(ctx, a)
+	if err != nil {
+		reportError(ctx, fmt.Errorf("predictor %q: %w", p.name, err))
+		return nil
+	}
+	return result
+}
+
+// contextFuncPredictor is a funcPredictor whose function takes a
+// context.Context, and is thus trusted to honour it.
+type contextFuncPredictor struct {
+	*funcPredictor
+}
+
+// Predict implements complete.Predictor
+func (p contextFuncPredictor) Predict(a complete.Args) []string {
+	return p.PredictContext(context.Background(), a)
+}
+
+// PredictContext implements ContextPredictor
+func (p contextFuncPredictor) PredictContext(ctx context.Context, a complete.Args) []string {
+	return p.predict(ctx, a)
+}
+
+func (p *funcPredictor) call(ctx context.Context, a complete.Args) ([]string, error) {
+	if p.opts.parser == nil {
+		return nil, fmt.Errorf("no parser available")
+	}
+	// The arguments are relative to the current subcommand, whereas the full
+	// command line is needed for tracing it.
+	completed := a.Completed
+	if full, ok := requestArgs(ctx); ok {
+		completed = full.Completed
+	}
+	// Tracing and applying the command line write to the model and the
+	// struct of the app, so the struct is restored afterwards.
+	appMu.Lock()
+	defer appMu.Unlock()
+	defer preserve(p.opts.parser.Model.Target)()
+	kctx, err := kong.Trace(p.opts.parser, completed)
+	if err != nil {
+		return nil, err
+	}
+	// Apply the command line as far as possible, so that providers can
+	// access the values of flags (e.g. the path of a config file). The
+	// command line is incomplete, so errors are expected here.
+	if err := kctx.Reset(); err == nil {
+		if err := kctx.Resolve(); err == nil {
+			_, _ = kctx.Apply()
+		}
+	}
+
+	node := kctx.Selected()
+	if node == nil {
+		node = kctx.Model.Node
+	}
+	for n := node; n != nil; n = n.Parent {
+		if err := bindNode(kctx, n); err != nil {
+			return nil, err
+		}
+	}
+	kctx.Bind(a)
+	kctx.BindTo(ctx, (*context.Context)(nil))
+
+	out, err := kctx.Call(p.fn.Interface())
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 2 && out[1] != nil {
+		return nil, out[1].(error)
+	}
+	result, _ := out[0].([]string)
+	return result, nil
+}
+
+// appMu serializes the predictor functions, since they share the struct of
+// the app with each other, and with the app itself (see REPL.Execute).
+var appMu sync.Mutex
+
+// preserve saves the value of target, and returns a function that restores
+// it.
+func preserve(target reflect.Value) (restore func()) {
+	if !target.IsValid() || !target.CanSet() {
+		return func() {}
+	}
+	saved := reflect.New(target.Type()).Elem()
+	saved.Set(target)
+	return func() { target.Set(saved) }
+}
+
+// bindNode binds the struct of a command, and its `Provide*` methods, the
+// same way as kong does for the Run methods of commands.
+func bindNode(kctx *kong.Context, node *kong.Node) error {
+	if !node.Target.IsValid() || !node.Target.CanAddr() {
+		return nil
+	}
+	kctx.Bind(node.Target.Addr().Interface())
+	for _, v := range []reflect.Value{node.Target, node.Target.Addr()} {
+		t := v.Type()
+		for i := 0; i < v.NumMethod(); i++ {
+			if !strings.HasPrefix(t.Method(i).Name, "Provide") {
+				continue
+			}
+			if err := kctx.BindToProvider(v.Method(i).Interface()); err != nil {
+				return fmt.Errorf("%s.%s: %w", t.Name(), t.Method(i).Name, err)
+			}
+		}
+	}
+	return nil
+}
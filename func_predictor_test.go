@@ -0,0 +1,91 @@
+package kongcompletion
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDB struct{ users []string }
+
+type testConfig struct{ prefix string }
+
+type funcPredictorApp struct {
+	Prefix string `kong:""`
+	Greet  struct {
+		Name string `kong:"arg,completion-predictor=users"`
+	} `kong:"cmd"`
+	Fail struct {
+		Name string `kong:"arg,completion-predictor=failing"`
+	} `kong:"cmd"`
+}
+
+func (a *funcPredictorApp) ProvideConfig() *testConfig {
+	return &testConfig{prefix: a.Prefix}
+}
+
+func TestWithPredictorFunc(t *testing.T) {
+	db := &testDB{users: []string{"ben", "liz", "bob"}}
+	parser := kong.Must(&funcPredictorApp{}, kong.Bind(db))
+
+	users := WithPredictorFunc("users", func(ctx context.Context, a complete.Args, db *testDB, cfg *testConfig) []string {
+		require.NotNil(t, ctx)
+		var result []string
+		for _, u := range db.users {
+			if strings.HasPrefix(u, cfg.prefix) {
+				result = append(result, u)
+			}
+		}
+		return result
+	})
+	failing := WithPredictorFunc("failing", func() ([]string, error) {
+		return []string{"ignored"}, errors.New("oh no")
+	})
+
+	t.Run("resolves bindings", func(t *testing.T) {
+		got, err := Complete(parser, "myApp greet ", 12, users, failing)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []Candidate{{Value: "ben"}, {Value: "liz"}, {Value: "bob"}}, got)
+	})
+
+	t.Run("resolves providers with flag values", func(t *testing.T) {
+		got, err := Complete(parser, "myApp --prefix=b greet ", 23, users, failing)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []Candidate{{Value: "ben"}, {Value: "bob"}}, got)
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		var errs []error
+		got, err := Complete(parser, "myApp fail ", 11, users, failing, WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}))
+		require.NoError(t, err)
+		assert.Empty(t, got)
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], `predictor "failing": oh no`)
+	})
+
+	t.Run("gives up on functions without a context", func(t *testing.T) {
+		slow := WithPredictorFunc("users", func() []string {
+			time.Sleep(time.Second)
+			return []string{"late"}
+		})
+		start := time.Now()
+		got, err := Complete(parser, "myApp greet ", 12, slow, failing, WithTimeout(20*time.Millisecond))
+		require.NoError(t, err)
+		assert.Empty(t, got)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("rejects invalid signature", func(t *testing.T) {
+		assert.Panics(t, func() { WithPredictorFunc("x", func() string { return "" }) })
+		assert.Panics(t, func() { WithPredictorFunc("x", "not a function") })
+	})
+}
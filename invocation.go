@@ -0,0 +1,240 @@
+package kongcompletion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/posener/complete"
+)
+
+// ContextPredictor is a predictor that supports cancellation. When the
+// context is done, it is supposed to return the results that it has
+// gathered so far.
+type ContextPredictor interface {
+	complete.Predictor
+	PredictContext(ctx context.Context, a complete.Args) []string
+}
+
+// ContextPredictFunc is a function that implements ContextPredictor
+type ContextPredictFunc func(ctx context.Context, a complete.Args) []string
+
+// Predict implements complete.Predictor
+func (p ContextPredictFunc) Predict(a complete.Args) []string {
+	return p.PredictContext(context.Background(), a)
+}
+
+// PredictContext implements ContextPredictor
+func (p ContextPredictFunc) PredictContext(ctx context.Context, a complete.Args) []string {
+	if p == nil {
+		return nil
+	}
+	return p(ctx, a)
+}
+
+// PanicError is reported when a predictor panics.
+type PanicError struct {
+	Value any    // The value that was passed to panic.
+	Stack []byte // The stack trace of the panicking goroutine.
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("predictor panicked: %v", e.Value)
+}
+
+// predictSafely invokes the predict function, and recovers from panics.
+func predictSafely(predict func() []string) (result []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return predict(), nil
+}
+
+// predictContext invokes the predictor, and gives up on it once the context
+// is done. Predictors that implement ContextPredictor are trusted to honour
+// the context themselves, so that they can return partial results. Panics
+// of the predictor are returned as *PanicError.
+func predictContext(ctx context.Context, predictor complete.Predictor, a complete.Args) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if p, ok := predictor.(ContextPredictor); ok {
+		result, err := predictSafely(func() []string { return p.PredictContext(ctx, a) })
+		if err != nil {
+			return result, err
+		}
+		return result, ctx.Err()
+	}
+	if p, ok := predictor.(requestPredictor); ok {
+		predictor = p.forRequest(ctx)
+	}
+	if ctx.Done() == nil {
+		// The context can’t be cancelled, so there is no need for the overhead.
+		return predictSafely(func() []string { return predictor.Predict(a) })
+	}
+	// Once the context is done, the predictor keeps running in the background,
+	// so in long-lived processes, the number of such predictors is limited.
+	slots, _ := ctx.Value(predictorSlotsKey{}).(chan struct{})
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		default:
+			return nil, errTooManyPredictors
+		}
+	}
+	type outcome struct {
+		result []string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		if slots != nil {
+			defer func() { <-slots }()
+		}
+		result, err := predictSafely(func() []string { return predictor.Predict(a) })
+		done <- outcome{result, err}
+	}()
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// requestPredictor is a predictor that depends on the context of the
+// completion request, although it can’t honour its deadline. Since it may
+// keep running after the request, it must not take the context from shared
+// state, but gets it bound before it is invoked.
+type requestPredictor interface {
+	forRequest(ctx context.Context) complete.Predictor
+}
+
+// invocation wraps the predictors of the completion tree, to take care of
+// tracing, deadlines and errors.
+type invocation struct {
+	opts      *options
+	target    string // What is being completed, e.g. `flag --name`.
+	name      string // The name of the predictor.
+	predictor complete.Predictor
+}
+
+func (opts *options) wrapPredictor(target string, name string, predictor complete.Predictor) complete.Predictor {
+	if predictor == nil {
+		// The nil predictor has a special meaning, so it must be retained.
+		return nil
+	}
+	return &invocation{
+		opts:      opts,
+		target:    target,
+		name:      name,
+		predictor: predictor,
+	}
+}
+
+// Predict implements complete.Predict
+func (p *invocation) Predict(a complete.Args) []string {
+	result, err := predictContext(p.opts.ctx, p.predictor, a)
+	if err != nil && ctxErr(err) {
+		p.opts.tracer.Debug("predictor timed out",
+			"target", p.target,
+			"predictor", p.name,
+			"candidates", len(result),
+			"error", err,
+		)
+		return result
+	}
+	if err != nil {
+		p.opts.reportError(fmt.Errorf("%s: %w", p.target, err))
+		return result
+	}
+	p.opts.tracer.Debug("invoked predictor",
+		"target", p.target,
+		"predictor", p.name,
+		"candidates", len(result),
+	)
+	return result
+}
+
+type predictorSlotsKey struct{}
+
+// errTooManyPredictors is returned if a predictor can’t be invoked, because
+// all slots are taken by predictors that are still running.
+var errTooManyPredictors = errors.New("too many predictors are still running")
+
+// withPredictorSlots limits the number of predictors that run in the
+// background at the same time to the capacity of slots. This includes the
+// ones that are still running after their deadline, which would otherwise
+// pile up in long-lived processes.
+func withPredictorSlots(ctx context.Context, slots chan struct{}) context.Context {
+	return context.WithValue(ctx, predictorSlotsKey{}, slots)
+}
+
+type requestArgsKey struct{}
+
+// withRequestArgs attaches the full command line of the completion request
+// to the context, since predictors only get the arguments of the current
+// subcommand.
+func withRequestArgs(ctx context.Context, a complete.Args) context.Context {
+	return context.WithValue(ctx, requestArgsKey{}, a)
+}
+
+// requestArgs returns the full command line of the completion request, if
+// attached to the context.
+func requestArgs(ctx context.Context) (complete.Args, bool) {
+	a, ok := ctx.Value(requestArgsKey{}).(complete.Args)
+	return a, ok
+}
+
+type appNameKey struct{}
+
+// withAppName attaches the name of the kong app that requests completions to
+// the context.
+func withAppName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, appNameKey{}, name)
+}
+
+// appName returns the name of the kong app that requests completions, if
+// attached to the context.
+func appName(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(appNameKey{}).(string)
+	return name, ok
+}
+
+type errorReporterKey struct{}
+
+// withErrorReporter attaches a callback to the context, through which
+// predictors can report errors that don’t prevent them from returning
+// results.
+func withErrorReporter(ctx context.Context, report func(error)) context.Context {
+	return context.WithValue(ctx, errorReporterKey{}, report)
+}
+
+// reportError passes the error to the reporter of the context, if any.
+func reportError(ctx context.Context, err error) {
+	if report, ok := ctx.Value(errorReporterKey{}).(func(error)); ok {
+		report(err)
+	}
+}
+
+// reportError routes errors that don’t abort the completion request to the
+// configured error handler, and records them in the trace.
+func (opts *options) reportError(err error) {
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		opts.tracer.Debug("predictor error", "error", err, "stack", string(panicErr.Stack))
+	} else {
+		opts.tracer.Debug("predictor error", "error", err)
+	}
+	if opts.errorHandler != nil {
+		opts.errorHandler(err)
+	}
+}
+
+// ctxErr reports whether the error originates from a cancelled context.
+func ctxErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,171 @@
+// Package kongcompletiontest provides helpers for testing the tab completion
+// behaviour of kong apps that use kong-completion.
+package kongcompletiontest
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	kongcompletion "github.com/jotaen/kong-completion"
+)
+
+// UpdateSnapshotsEnvVar is the environment variable that makes AssertSnapshot
+// (re-)write the snapshot files instead of comparing against them.
+const UpdateSnapshotsEnvVar = "UPDATE_SNAPSHOTS"
+
+// Completer computes completions for a kong app.
+type Completer struct {
+	parser  *kong.Kong
+	options []kongcompletion.Option
+}
+
+// Case is a test case for Completer.Run.
+type Case struct {
+	Name string   // The name of the subtest. Defaults to Line.
+	Line string   // The command line, including the name of the binary.
+	Want []string // The expected candidates, in any order.
+}
+
+// New returns a Completer for the given kong app. The options are the same
+// that are passed to kongcompletion.Register, e.g. custom predictors.
+func New(parser *kong.Kong, opt ...kongcompletion.Option) *Completer {
+	return &Completer{
+		parser:  parser,
+		options: opt,
+	}
+}
+
+// Assert checks that completing line yields the wanted candidates.
+func Assert(t testing.TB, parser *kong.Kong, line string, want ...string) {
+	t.Helper()
+	New(parser).Assert(t, line, want...)
+}
+
+// Complete returns the candidates for completing line, where the cursor is
+// assumed to be at the end of the line. It fails the test on errors.
+func (c *Completer) Complete(t testing.TB, line string) []string {
+	t.Helper()
+	candidates, err := kongcompletion.Complete(c.parser, line, len(line), c.options...)
+	if err != nil {
+		t.Fatalf("completing %q: %v", line, err)
+	}
+	values := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		values[i] = candidate.Value
+	}
+	return values
+}
+
+// Assert checks that completing line yields the wanted candidates, regardless
+// of their order.
+func (c *Completer) Assert(t testing.TB, line string, want ...string) {
+	t.Helper()
+	got := c.Complete(t, line)
+	missing, unexpected := diff(want, got)
+	if len(missing) > 0 || len(unexpected) > 0 {
+		t.Errorf("completing %q:\n  missing:    %q\n  unexpected: %q", line, missing, unexpected)
+	}
+}
+
+// Run runs each case as subtest.
+func (c *Completer) Run(t *testing.T, cases []Case) {
+	t.Helper()
+	for _, tc := range cases {
+		name := tc.Name
+		if name == "" {
+			name = tc.Line
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Helper()
+			c.Assert(t, tc.Line, tc.Want...)
+		})
+	}
+}
+
+// AssertSnapshot compares the candidates for all lines with a snapshot file
+// in the testdata directory, which is named after the test. If the
+// UPDATE_SNAPSHOTS environment variable is set, it writes the snapshot file
+// instead.
+func (c *Completer) AssertSnapshot(t testing.TB, lines ...string) {
+	t.Helper()
+	var snapshot strings.Builder
+	for _, line := range lines {
+		got := c.Complete(t, line)
+		slices.Sort(got)
+		snapshot.WriteString("$ " + line + "\n")
+		for _, value := range got {
+			snapshot.WriteString(value + "\n")
+		}
+		snapshot.WriteString("\n")
+	}
+
+	assertSnapshot(t, "completions", snapshotFileName(t.Name())+".snapshot", snapshot.String())
+}
+
+// AssertModelSnapshot compares the completion model of the app, as returned
+// by kongcompletion.Dump, with a JSON file in the testdata directory, which is
+// named after the test. That way, changes to the completion behaviour show up
+// in code review. If the UPDATE_SNAPSHOTS environment variable is set, it
+// writes the snapshot file instead.
+func (c *Completer) AssertModelSnapshot(t testing.TB) {
+	t.Helper()
+	dump, err := kongcompletion.Dump(c.parser, c.options...)
+	if err != nil {
+		t.Fatalf("dumping completion model: %v", err)
+	}
+	assertSnapshot(t, "completion model", snapshotFileName(t.Name())+".json", string(dump)+"\n")
+}
+
+// assertSnapshot compares the content with the snapshot file, or writes it.
+func assertSnapshot(t testing.TB, subject string, fileName string, content string) {
+	t.Helper()
+	path := filepath.Join("testdata", fileName)
+	if os.Getenv(UpdateSnapshotsEnvVar) != "" {
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(content), 0o644)
+		}
+		if err != nil {
+			t.Fatalf("writing snapshot: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading snapshot (run with %s=1 to create it): %v", UpdateSnapshotsEnvVar, err)
+	}
+	if string(want) != content {
+		t.Errorf("%s doesn’t match snapshot %s (run with %s=1 to update it)\n--- want:\n%s\n--- got:\n%s",
+			subject, path, UpdateSnapshotsEnvVar, want, content)
+	}
+}
+
+// diff returns the values that are missing in got, and the ones that are in
+// got, but not in want.
+func diff(want, got []string) (missing, unexpected []string) {
+	remaining := slices.Clone(got)
+	for _, w := range want {
+		i := slices.Index(remaining, w)
+		if i < 0 {
+			missing = append(missing, w)
+			continue
+		}
+		remaining = slices.Delete(remaining, i, i+1)
+	}
+	return missing, remaining
+}
+
+// snapshotFileName derives a file name from the name of a (sub)test.
+func snapshotFileName(testName string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, testName)
+}
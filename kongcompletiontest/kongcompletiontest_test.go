@@ -0,0 +1,55 @@
+package kongcompletiontest
+
+import (
+	"testing"
+
+	"github.com/alecthomas/kong"
+	kongcompletion "github.com/jotaen/kong-completion"
+	"github.com/posener/complete"
+	"github.com/stretchr/testify/assert"
+)
+
+type app struct {
+	Greet struct {
+		Loud bool   `kong:""`
+		Name string `kong:"arg,completion-predictor=names"`
+	} `kong:"cmd"`
+	Wave struct{} `kong:"cmd,aliases=hi"`
+}
+
+var names = kongcompletion.WithPredictor("names", complete.PredictSet("Ben", "Liz"))
+
+func TestAssert(t *testing.T) {
+	var cli struct {
+		Greet struct{} `kong:"cmd"`
+		Wave  struct{} `kong:"cmd,aliases=hi"`
+	}
+	Assert(t, kong.Must(&cli), "myApp ", "greet", "wave", "hi")
+	Assert(t, kong.Must(&cli), "myApp gr", "greet")
+}
+
+func TestCompleterRun(t *testing.T) {
+	New(kong.Must(&app{}), names).Run(t, []Case{
+		{Line: "myApp greet ", Want: []string{"Ben", "Liz"}},
+		{Line: "myApp greet L", Want: []string{"Liz"}},
+		{Name: "flags", Line: "myApp greet --l", Want: []string{"--loud"}},
+	})
+}
+
+func TestCompleterSnapshot(t *testing.T) {
+	New(kong.Must(&app{}), names).AssertSnapshot(t,
+		"myApp ",
+		"myApp greet -",
+		"myApp greet ",
+	)
+}
+
+func TestCompleterModelSnapshot(t *testing.T) {
+	New(kong.Must(&app{}, kong.Name("myApp")), names).AssertModelSnapshot(t)
+}
+
+func Test_diff(t *testing.T) {
+	missing, unexpected := diff([]string{"a", "b", "b"}, []string{"b", "c", "a"})
+	assert.Equal(t, []string{"b"}, missing)
+	assert.Equal(t, []string{"c"}, unexpected)
+}
@@ -0,0 +1,56 @@
+package kongcompletion
+
+import (
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+)
+
+// resolvePredictor resolves the predictor of a value right away, unless the
+// tree is built for a single completion request. In that case, resolving is
+// deferred until the predictor is actually invoked, so that only the
+// predictors at the cursor position are looked up.
+func (opts *options) resolvePredictor(value *kong.Value, resolve func() (complete.Predictor, error)) (complete.Predictor, error) {
+	if opts.selected == nil {
+		return resolve()
+	}
+	if !mayPredict(value) {
+		// The nil predictor has a special meaning, so it can’t be deferred.
+		return nil, nil
+	}
+	return &lazyPredictor{opts: opts, resolve: resolve}, nil
+}
+
+// mayPredict reports whether valuePredictor would come up with a non-nil
+// predictor for the value.
+func mayPredict(value *kong.Value) bool {
+	if value == nil {
+		return false
+	}
+	return value.Tag.Has(predictorTag) || value.Tag.Has(predictorCmdTag) || !value.IsBool()
+}
+
+// lazyPredictor resolves its predictor on first use. An error that occurs
+// while resolving is recorded with the options whenever the predictor is
+// used, since it is supposed to fail the completion request as a whole.
+type lazyPredictor struct {
+	opts      *options
+	resolve   func() (complete.Predictor, error)
+	resolved  bool
+	predictor complete.Predictor
+	err       error
+}
+
+// Predict implements complete.Predict
+func (p *lazyPredictor) Predict(a complete.Args) []string {
+	if !p.resolved {
+		p.resolved = true
+		p.predictor, p.err = p.resolve()
+	}
+	if p.err != nil && p.opts.deferredErr == nil {
+		p.opts.deferredErr = p.err
+	}
+	if p.predictor == nil {
+		return nil
+	}
+	return p.predictor.Predict(a)
+}
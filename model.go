@@ -0,0 +1,184 @@
+package kongcompletion
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/alecthomas/kong"
+)
+
+// modelCommand is a static representation of a (sub)command of a kong app,
+// as far as it is relevant for completion. Unlike the tree that nodeCommand
+// builds, it doesn’t contain any predictors, so it can be compiled into
+// other formats, e.g. self-contained shell scripts.
+type modelCommand struct {
+	Name     string
+	Aliases  []string
+	Help     string
+	Flags    []modelFlag // The flags of this command, excluding those of its parents.
+	Args     []modelValue
+	Commands []*modelCommand
+}
+
+// modelFlag is a flag of a modelCommand.
+type modelFlag struct {
+	Name    string
+	Short   rune
+	Aliases []string
+	Help    string
+	Bool    bool
+	Value   modelValue
+}
+
+// modelValue describes how the value of a flag or a positional argument is
+// completed. At most one of Predictor, Command, Values and Hint is set.
+type modelValue struct {
+	Name       string
+	Help       string
+	Predictor  string   // The name of the predictor that computes the candidates at runtime.
+	Command    []string // The external command whose output lines are the candidates.
+	Values     []string // The fixed candidates of an enum.
+	Hint       pathHint // The kind of path, if the value is a path.
+	Cumulative bool     // Whether the value can appear multiple times.
+	Required   bool
+}
+
+// pathHint is the kind of path that a value denotes.
+type pathHint string
+
+const (
+	hintNone pathHint = ""
+	hintFile pathHint = "file"
+	hintDir  pathHint = "dir"
+)
+
+// buildModel derives the completion model of a kong app. Commands, flags and
+// arguments that completion is disabled for are left out.
+func buildModel(parser *kong.Kong) (*modelCommand, error) {
+	if parser == nil || parser.Model == nil {
+		return &modelCommand{}, nil
+	}
+	return modelNode(parser.Model.Node, nil)
+}
+
+func modelNode(node *kong.Node, vars kong.Vars) (*modelCommand, error) {
+	vars = vars.CloneWith(node.Vars())
+	cmd := &modelCommand{
+		Name:    node.Name,
+		Aliases: node.Aliases,
+		Help:    node.Help,
+	}
+	for _, flag := range node.Flags {
+		if flag == nil || !isCompletionEnabled(flag.Tag) {
+			continue
+		}
+		value, err := modelValueOf(flag.Value, vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s --%s: %w", node.FullPath(), flag.Name, err)
+		}
+		cmd.Flags = append(cmd.Flags, modelFlag{
+			Name:    flag.Name,
+			Short:   flag.Short,
+			Aliases: flag.Aliases,
+			Help:    flag.Help,
+			Bool:    flag.Value.IsBool(),
+			Value:   value,
+		})
+	}
+	for _, arg := range node.Positional {
+		value, err := modelValueOf(arg, vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s <%s>: %w", node.FullPath(), arg.Name, err)
+		}
+		cmd.Args = append(cmd.Args, value)
+	}
+	for _, child := range node.Children {
+		if child == nil || !isCompletionEnabled(child.Tag) {
+			continue
+		}
+		childCmd, err := modelNode(child, vars)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Commands = append(cmd.Commands, childCmd)
+	}
+	return cmd, nil
+}
+
+// modelValueOf mirrors the precedence of valuePredictor.
+func modelValueOf(value *kong.Value, vars kong.Vars) (modelValue, error) {
+	v := modelValue{
+		Name:       value.Name,
+		Help:       value.Help,
+		Cumulative: value.IsCumulative(),
+		Required:   value.Required,
+	}
+	vars = vars.CloneWith(value.Tag.Vars)
+	switch {
+	case value.Tag.Has(predictorTag):
+		name, err := interpolate(value.Tag.Get(predictorTag), vars, nil)
+		if err != nil {
+			return v, fmt.Errorf("interpolating predictor name %q: %w", value.Tag.Get(predictorTag), err)
+		}
+		v.Predictor = name
+	case value.Tag.Has(predictorCmdTag):
+		commandLine, err := interpolate(value.Tag.Get(predictorCmdTag), vars, nil)
+		if err != nil {
+			return v, fmt.Errorf("interpolating predictor command %q: %w", value.Tag.Get(predictorCmdTag), err)
+		}
+		predictor, err := newCommandPredictor(commandLine)
+		if err != nil {
+			return v, fmt.Errorf("parsing predictor command %q: %w", commandLine, err)
+		}
+		v.Command = predictor.args
+	case value.IsBool():
+	case value.Enum != "":
+		for _, enumVal := range value.EnumSlice() {
+			if enumVal != "" {
+				v.Values = append(v.Values, enumVal)
+			}
+		}
+	default:
+		v.Hint = valuePathHint(value)
+	}
+	return v, nil
+}
+
+var fileTypes = []reflect.Type{
+	reflect.TypeFor[*os.File](),
+	reflect.TypeFor[kong.FileContentFlag](),
+	reflect.TypeFor[kong.NamedFileContentFlag](),
+}
+
+// valuePathHint determines the kind of path from the kong type of the value.
+func valuePathHint(value *kong.Value) pathHint {
+	switch value.Tag.Type {
+	case "path", "existingfile", "filecontent":
+		return hintFile
+	case "existingdir":
+		return hintDir
+	}
+	typ := value.Target.Type()
+	if value.IsSlice() {
+		typ = typ.Elem()
+	}
+	for _, fileType := range fileTypes {
+		if typ == fileType {
+			return hintFile
+		}
+	}
+	return hintNone
+}
+
+// flagNames returns all spellings of the flag, including the hyphens.
+func (f modelFlag) flagNames() []string {
+	names := []string{"--" + f.Name}
+	if f.Short != 0 {
+		names = append(names, "-"+string(f.Short))
+	}
+	for _, alias := range f.Aliases {
+		names = append(names, "--"+alias)
+	}
+	return names
+}
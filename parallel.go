@@ -0,0 +1,75 @@
+package kongcompletion
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/posener/complete"
+)
+
+// ParallelPredictor runs several predictors concurrently, and merges their
+// results. The results are de-duplicated, and ordered by the position of the
+// predictors, i.e. the results of the first predictor come first.
+//
+// Errors of the individual predictors, i.e. panics or exceeded deadlines, are
+// passed to the error handler (see WithErrorHandler). The results of the
+// other predictors are returned regardless.
+type ParallelPredictor struct {
+	Predictors []complete.Predictor
+
+	// Timeout is the deadline that is shared by all predictors. If zero,
+	// only the deadline of the completion request applies (see WithTimeout).
+	Timeout time.Duration
+}
+
+// PredictParallel runs the predictors concurrently, and merges their results
+func PredictParallel(predictors ...complete.Predictor) *ParallelPredictor {
+	return &ParallelPredictor{
+		Predictors: predictors,
+	}
+}
+
+// Predict implements complete.Predictor
+func (p *ParallelPredictor) Predict(a complete.Args) []string {
+	return p.PredictContext(context.Background(), a)
+}
+
+// PredictContext implements ContextPredictor
+func (p *ParallelPredictor) PredictContext(ctx context.Context, a complete.Args) []string {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	results := make([][]string, len(p.Predictors))
+	errs := make([]error, len(p.Predictors))
+	var wg sync.WaitGroup
+	for i, predictor := range p.Predictors {
+		if predictor == nil {
+			continue
+		}
+		wg.Go(func() {
+			results[i], errs[i] = predictContext(ctx, predictor, a)
+		})
+	}
+	wg.Wait()
+
+	var merged []string
+	seen := map[string]bool{}
+	for i, result := range results {
+		if errs[i] != nil {
+			reportError(ctx, fmt.Errorf("parallel predictor #%d: %w", i+1, errs[i]))
+		}
+		for _, candidate := range result {
+			if seen[candidate] {
+				continue
+			}
+			seen[candidate] = true
+			merged = append(merged, candidate)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,64 @@
+package kongcompletion
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPredictParallel(t *testing.T) {
+	slow := complete.PredictFunc(func(complete.Args) []string {
+		time.Sleep(20 * time.Millisecond)
+		return []string{"c", "a"}
+	})
+
+	t.Run("merges results in order", func(t *testing.T) {
+		p := PredictParallel(slow, complete.PredictSet("b", "c"), nil)
+		assert.Equal(t, []string{"c", "a", "b"}, p.Predict(newArgs("app ")))
+	})
+
+	t.Run("reports member errors", func(t *testing.T) {
+		var cli struct {
+			Things string `kong:"arg,completion-predictor=things"`
+		}
+		blocking := make(chan struct{})
+		defer close(blocking)
+		p := PredictParallel(
+			complete.PredictFunc(func(complete.Args) []string { panic("oh no") }),
+			complete.PredictSet("b"),
+			complete.PredictFunc(func(complete.Args) []string {
+				<-blocking
+				return []string{"never"}
+			}),
+		)
+		p.Timeout = 10 * time.Millisecond
+
+		var errs []error
+		got, err := Complete(kong.Must(&cli), "myApp ", 6,
+			WithPredictor("things", p),
+			WithErrorHandler(func(err error) { errs = append(errs, err) }),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, []Candidate{{Value: "b"}}, got)
+		require.Len(t, errs, 2)
+		assert.EqualError(t, errs[0], "parallel predictor #1: predictor panicked: oh no")
+		assert.EqualError(t, errs[1], "parallel predictor #3: context deadline exceeded")
+	})
+
+	t.Run("recovers panics without a deadline", func(t *testing.T) {
+		p := PredictParallel(
+			complete.PredictFunc(func(complete.Args) []string { panic("oh no") }),
+			complete.PredictSet("b"),
+		)
+		var errs []error
+		ctx := withErrorReporter(context.Background(), func(err error) { errs = append(errs, err) })
+		assert.Equal(t, []string{"b"}, p.PredictContext(ctx, newArgs("app ")))
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], "parallel predictor #1: predictor panicked: oh no")
+	})
+}
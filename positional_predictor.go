@@ -1,6 +1,7 @@
 package kongcompletion
 
 import (
+	"log/slog"
 	"strings"
 
 	"github.com/posener/complete"
@@ -12,6 +13,7 @@ type PositionalPredictor struct {
 	ArgFlags             []string
 	BoolFlags            []string
 	LastFlagIsCumulative bool // “Cumulative” means the last flag can appear multiple times.
+	tracer               *slog.Logger
 }
 
 // Predict implements complete.Predict
@@ -26,6 +28,9 @@ func (p *PositionalPredictor) Predict(a complete.Args) []string {
 func (p *PositionalPredictor) predictor(a complete.Args) complete.Predictor {
 	position := p.predictorIndex(a)
 	complete.Log("predicting positional argument(%d)", position)
+	if p.tracer != nil {
+		p.tracer.Debug("selected positional slot", "position", position, "slots", len(p.Predictors))
+	}
 	if position < 0 || position > len(p.Predictors)-1 {
 		if p.LastFlagIsCumulative && len(p.Predictors) > 0 {
 			return p.Predictors[len(p.Predictors)-1]
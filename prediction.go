@@ -1,8 +1,14 @@
 package kongcompletion
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"os"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/posener/complete"
@@ -13,10 +19,32 @@ const (
 	enabledTag   = "completion-enabled"
 )
 
+// The environment variables via which shells pass completion requests.
+const (
+	envLine  = "COMP_LINE"
+	envPoint = "COMP_POINT"
+)
+
 type options struct {
 	predictors   map[string]complete.Predictor
 	exitFunc     func(code int)
 	errorHandler func(error)
+	tracer       *slog.Logger
+	timeout      time.Duration
+	parser       *kong.Kong
+	tolerant     bool
+
+	// ctx is the context of the current completion request. It is only read
+	// while the request is processed, whereas predictors that outlive the
+	// request get it passed along (see predictContext).
+	ctx context.Context
+	// selected holds the nodes along the command path of the current
+	// completion request. If set, only those nodes are built in full, and
+	// their predictors are resolved lazily.
+	selected map[*kong.Node]bool
+	// deferredErr is the first error that occurred while resolving a predictor
+	// lazily.
+	deferredErr error
 }
 
 // Option is a configuration option for running Register
@@ -48,6 +76,23 @@ func WithExitFunc(exitFunc func(code int)) Option {
 	}
 }
 
+// WithTimeout the time budget for computing completions, after which the
+// predictors are cancelled
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithTolerantMode don’t fail if the predictor of a flag or positional
+// argument can’t be determined, but report the error to the error handler,
+// and fall back to complete.PredictAnything for that flag or argument
+func WithTolerantMode() Option {
+	return func(o *options) {
+		o.tolerant = true
+	}
+}
+
 // WithErrorHandler handle errors with completions
 func WithErrorHandler(handler func(error)) Option {
 	return func(o *options) {
@@ -62,15 +107,25 @@ func buildOptions(opt ...Option) *options {
 	for _, o := range opt {
 		o(opts)
 	}
+	if opts.tracer == nil {
+		opts.tracer = slog.New(slog.DiscardHandler)
+	}
+	opts.ctx = context.Background()
 	return opts
 }
 
-// Command returns a completion Command for a kong parser
+// Command returns a completion Command for a kong parser. Unlike Complete,
+// which only builds what is needed for a single completion request, it builds
+// the entire tree of (sub)commands up front.
 func Command(parser *kong.Kong, opt ...Option) (complete.Command, error) {
-	opts := buildOptions(opt...)
+	return command(parser, buildOptions(opt...))
+}
+
+func command(parser *kong.Kong, opts *options) (complete.Command, error) {
 	if parser == nil || parser.Model == nil {
 		return complete.Command{}, nil
 	}
+	opts.parser = parser
 	command, err := nodeCommand(parser.Model.Node, opts, nil, flags{})
 	if err != nil {
 		return complete.Command{}, err
@@ -78,11 +133,118 @@ func Command(parser *kong.Kong, opt ...Option) (complete.Command, error) {
 	return *command, err
 }
 
+// Candidate is a possible completion for the word under the cursor.
+type Candidate struct {
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"` // The help text, in case the candidate is a command or a flag.
+}
+
+// Complete computes the completion candidates for the given command line,
+// where point is the cursor position within line. The first word of line is
+// the name of the binary. Unlike Register, it doesn’t consult the process
+// environment, and it doesn’t exit.
+func Complete(parser *kong.Kong, line string, point int, opt ...Option) ([]Candidate, error) {
+	return CompleteContext(context.Background(), parser, line, point, opt...)
+}
+
+// CompleteContext is like Complete, but the predictors are cancelled when
+// ctx is done. In that case, it returns the candidates gathered so far.
+func CompleteContext(ctx context.Context, parser *kong.Kong, line string, point int, opt ...Option) ([]Candidate, error) {
+	if point >= 0 && point < len(line) {
+		line = line[:point]
+	}
+	complete.Log("Completing phrase: %s", line)
+	return completeArgs(ctx, parser, newArgs(line), opt...)
+}
+
+// completeArgs computes the completion candidates for a command line that is
+// already tokenized.
+func completeArgs(ctx context.Context, parser *kong.Kong, a complete.Args, opt ...Option) ([]Candidate, error) {
+	opts := buildOptions(opt...)
+	cancel := opts.beginRequest(ctx, parser, a)
+	defer cancel()
+
+	var node *kong.Node
+	if parser != nil && parser.Model != nil {
+		node = selectNode(parser.Model.Node, a)
+		traceSelection(opts.tracer, a, node)
+		opts.selected = map[*kong.Node]bool{}
+		for n := node; n != nil; n = n.Parent {
+			opts.selected[n] = true
+		}
+	}
+	cmd, err := command(parser, opts)
+	if err != nil {
+		return nil, err
+	}
+	return predictCandidates(cmd, opts, a, node)
+}
+
+// beginRequest prepares the options for computing the completions of a
+// command line. The returned function releases the resources of the request.
+func (opts *options) beginRequest(ctx context.Context, parser *kong.Kong, a complete.Args) context.CancelFunc {
+	opts.ctx = withRequestArgs(withErrorReporter(ctx, opts.reportError), a)
+	if parser != nil && parser.Model != nil {
+		opts.ctx = withAppName(opts.ctx, parser.Model.Name)
+	}
+	cancel := func() {}
+	if opts.timeout > 0 {
+		opts.ctx, cancel = context.WithTimeout(opts.ctx, opts.timeout)
+	}
+	opts.deferredErr = nil
+	return cancel
+}
+
+// predictCandidates runs the predictions of cmd, and describes them with the
+// help texts of the subcommands and flags that are available at node.
+func predictCandidates(cmd complete.Command, opts *options, a complete.Args, node *kong.Node) ([]Candidate, error) {
+	predictions := cmd.Predict(a)
+	if opts.deferredErr != nil {
+		return nil, opts.deferredErr
+	}
+	var descriptions map[string]string
+	if node != nil {
+		descriptions = describeNames(node)
+	}
+	candidates := []Candidate{}
+	for _, value := range predictions {
+		// Only keep the options that match the word under the cursor.
+		if !strings.HasPrefix(value, a.Last) {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Value:       value,
+			Description: descriptions[value],
+		})
+	}
+	opts.tracer.Debug("completed", "candidates", len(candidates))
+	return candidates, nil
+}
+
+// completeCmd is the pseudo subcommand via which tools other than the shells
+// (e.g. autocomplete specs) request completions. It takes the words after
+// the binary name, the last of which is the one under the cursor, e.g.
+// `greet __complete greet --sty`. It prints the candidates along with their
+// descriptions, separated by a tab.
+const completeCmd = "__complete"
+
 // Register configures a kong app for intercepting completions.
 func Register(parser *kong.Kong, opt ...Option) {
 	if parser == nil {
 		return
 	}
+	server := len(os.Args) > 1 && os.Args[1] == completeServerCmd
+	a, describe, ok := completionRequest()
+	if !ok && !server {
+		return
+	}
+	if tracePath := os.Getenv(traceEnvVar); tracePath != "" {
+		tracer, closeTrace, err := openTraceFile(tracePath)
+		if err == nil {
+			defer closeTrace()
+			opt = append([]Option{WithTracer(tracer)}, opt...)
+		}
+	}
 	opts := buildOptions(opt...)
 	errHandler := opts.errorHandler
 	if errHandler == nil {
@@ -94,18 +256,102 @@ func Register(parser *kong.Kong, opt ...Option) {
 	if exitFunc == nil {
 		exitFunc = parser.Exit
 	}
-	cmd, err := Command(parser, opt...)
+	if server {
+		err := serve(parser, os.Stdin, opt...)
+		if err != nil {
+			errHandler(err)
+			exitFunc(1)
+			return
+		}
+		exitFunc(0)
+		return
+	}
+	if !describe {
+		// Only the shell scripts pass the protocol version, whereas other
+		// tools invoke completeCmd directly.
+		checkProtocol(parser, opts.tracer)
+	}
+	candidates, err := completeArgs(context.Background(), parser, a, opt...)
 	if err != nil {
 		errHandler(err)
 		exitFunc(1)
+		return
+	}
+	for _, candidate := range candidates {
+		if describe && candidate.Description != "" {
+			_, _ = fmt.Fprintf(parser.Stdout, "%s\t%s\n", candidate.Value, firstLine(candidate.Description))
+			continue
+		}
+		_, _ = fmt.Fprintln(parser.Stdout, candidate.Value)
 	}
+	exitFunc(0)
+}
 
-	cmp := complete.New(parser.Model.Name, cmd)
-	cmp.Out = parser.Stdout
-	done := cmp.Complete()
-	if done {
-		exitFunc(0)
+// completionRequest returns the command line to complete, if the process was
+// invoked for computing completions, either by the shell, or via completeCmd.
+// In the latter case, the candidates are supposed to be described.
+func completionRequest() (a complete.Args, describe bool, ok bool) {
+	if len(os.Args) > 1 && os.Args[1] == completeCmd {
+		words := slices.Clone(os.Args[2:])
+		if len(words) == 0 {
+			words = []string{""}
+		}
+		complete.Log("Completing words: %q", words)
+		return argsFromFields(append([]string{os.Args[0]}, splitLastEqual(words)...)), true, true
+	}
+	line := os.Getenv(envLine)
+	if line == "" {
+		return complete.Args{}, false, false
 	}
+	point, err := strconv.Atoi(os.Getenv(envPoint))
+	if err == nil && point >= 0 && point < len(line) {
+		line = line[:point]
+	}
+	// Otherwise, assume the cursor to be at the end of the line.
+	complete.Log("Completing phrase: %s", line)
+	return newArgs(line), false, true
+}
+
+// selectNode returns the node of the (sub)command that the arguments refer to.
+func selectNode(node *kong.Node, a complete.Args) *kong.Node {
+	for _, arg := range a.Completed {
+		for _, child := range node.Children {
+			if child == nil || !isCompletionEnabled(child.Tag) {
+				continue
+			}
+			if child.Name == arg || slices.Contains(child.Aliases, arg) {
+				node = child
+				break
+			}
+		}
+	}
+	return node
+}
+
+// describeNames maps the spellings of all subcommands and flags that are
+// available at the node to their help texts.
+func describeNames(node *kong.Node) map[string]string {
+	descriptions := map[string]string{}
+	for _, child := range node.Children {
+		if child == nil {
+			continue
+		}
+		descriptions[child.Name] = child.Help
+		for _, alias := range child.Aliases {
+			descriptions[alias] = child.Help
+		}
+	}
+	for n := node; n != nil; n = n.Parent {
+		for _, flag := range n.Flags {
+			if flag == nil {
+				continue
+			}
+			for _, name := range flagNamesWithHyphens(flag) {
+				descriptions[name] = flag.Help
+			}
+		}
+	}
+	return descriptions
 }
 
 type flags struct {
@@ -132,6 +378,15 @@ func nodeCommand(node *kong.Node, opts *options, vars kong.Vars, flags flags) (*
 		if child == nil || !isCompletionEnabled(child.Tag) {
 			continue
 		}
+		if opts.selected != nil && !opts.selected[child] {
+			// Off the command path, only the spellings of the subcommand
+			// matter, so there is no need to build it.
+			cmd.Sub[child.Name] = complete.Command{}
+			for _, alias := range child.Aliases {
+				cmd.Sub[alias] = complete.Command{}
+			}
+			continue
+		}
 		childCmd, err := nodeCommand(child, opts, vars, flags)
 		if err != nil {
 			return nil, err
@@ -148,7 +403,14 @@ func nodeCommand(node *kong.Node, opts *options, vars kong.Vars, flags flags) (*
 		if flag == nil || !isCompletionEnabled(flag.Tag) {
 			continue
 		}
-		predictor, err := flagPredictor(flag, opts.predictors, vars)
+		predictor, err := opts.resolvePredictor(flag.Value, func() (complete.Predictor, error) {
+			predictor, err := flagPredictor(flag, opts.predictors, vars)
+			predictor, err = opts.tolerate(node.FullPath()+" --"+flag.Name, predictor, err)
+			if err != nil {
+				return nil, err
+			}
+			return opts.wrapPredictor("flag --"+flag.Name, predictorName(flag.Value, vars), predictor), nil
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -157,7 +419,7 @@ func nodeCommand(node *kong.Node, opts *options, vars kong.Vars, flags flags) (*
 		}
 	}
 
-	pps, err := positionalPredictors(node.Positional, opts.predictors, vars)
+	pps, err := positionalPredictors(node, opts, vars)
 	if err != nil {
 		return nil, err
 	}
@@ -167,11 +429,22 @@ func nodeCommand(node *kong.Node, opts *options, vars kong.Vars, flags flags) (*
 		ArgFlags:             flagNamesWithHyphens(flags.argFlags...),
 		BoolFlags:            flagNamesWithHyphens(flags.boolFlags...),
 		LastFlagIsCumulative: lastIsCumulative,
+		tracer:               opts.tracer,
 	}
 
 	return &cmd, nil
 }
 
+// tolerate swallows the error in tolerant mode, in which case it reports the
+// error and returns a fallback predictor instead.
+func (opts *options) tolerate(path string, predictor complete.Predictor, err error) (complete.Predictor, error) {
+	if err == nil || !opts.tolerant {
+		return predictor, err
+	}
+	opts.reportError(fmt.Errorf("%s: %w", path, err))
+	return complete.PredictAnything, nil
+}
+
 func isCompletionEnabled(tag *kong.Tag) bool {
 	v := tag.Get(enabledTag)
 	if v == "false" {
@@ -231,11 +504,9 @@ func tagPredictor(tag kongTag, predictors map[string]complete.Predictor, vars ko
 	if predictors == nil {
 		predictors = map[string]complete.Predictor{}
 	}
-	predictorName := tag.Get(predictorTag)
-
-	predictorName, err := interpolate(predictorName, vars, nil)
+	predictorName, err := interpolate(tag.Get(predictorTag), vars, nil)
 	if err != nil {
-		return nil, fmt.Errorf("interpolating predictor name %q: %w", predictorName, err)
+		return nil, fmt.Errorf("interpolating predictor name %q: %w", tag.Get(predictorTag), err)
 	}
 
 	predictor, ok := predictors[predictorName]
@@ -256,6 +527,17 @@ func valuePredictor(value *kong.Value, predictors map[string]complete.Predictor,
 	if predictor != nil {
 		return predictor, nil
 	}
+	if value.Tag.Has(predictorCmdTag) {
+		commandLine, err := interpolate(value.Tag.Get(predictorCmdTag), vars.CloneWith(value.Tag.Vars), nil)
+		if err != nil {
+			return nil, fmt.Errorf("interpolating predictor command %q: %w", value.Tag.Get(predictorCmdTag), err)
+		}
+		predictor, err := newCommandPredictor(commandLine)
+		if err != nil {
+			return nil, fmt.Errorf("parsing predictor command %q: %w", commandLine, err)
+		}
+		return predictor, nil
+	}
 	switch {
 	case value.IsBool():
 		return complete.PredictNothing, nil
@@ -270,14 +552,21 @@ func valuePredictor(value *kong.Value, predictors map[string]complete.Predictor,
 	}
 }
 
-func positionalPredictors(args []*kong.Positional, predictors map[string]complete.Predictor, vars kong.Vars) ([]complete.Predictor, error) {
-	res := make([]complete.Predictor, len(args))
-	var err error
-	for i, arg := range args {
-		res[i], err = valuePredictor(arg, predictors, vars)
+func positionalPredictors(node *kong.Node, opts *options, vars kong.Vars) ([]complete.Predictor, error) {
+	res := make([]complete.Predictor, len(node.Positional))
+	for i, arg := range node.Positional {
+		predictor, err := opts.resolvePredictor(arg, func() (complete.Predictor, error) {
+			predictor, err := valuePredictor(arg, opts.predictors, vars)
+			predictor, err = opts.tolerate(node.FullPath()+" <"+arg.Name+">", predictor, err)
+			if err != nil {
+				return nil, err
+			}
+			return opts.wrapPredictor("positional "+arg.Name, predictorName(arg, vars), predictor), nil
+		})
 		if err != nil {
 			return nil, err
 		}
+		res[i] = predictor
 	}
 	return res, nil
 }
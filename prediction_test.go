@@ -2,10 +2,13 @@ package kongcompletion
 
 import (
 	"bytes"
+	"context"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/posener/complete"
@@ -13,11 +16,6 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-const (
-	envLine  = "COMP_LINE"
-	envPoint = "COMP_POINT"
-)
-
 func TestComplete(t *testing.T) {
 	type embed struct {
 		Lion string
@@ -181,6 +179,213 @@ func TestComplete(t *testing.T) {
 	}
 }
 
+func TestCompleteCandidates(t *testing.T) {
+	var cli struct {
+		Verbose bool `kong:"short=v,help='Print more output'"`
+		Greet   struct {
+			Name string `kong:"arg,enum='Ben,Liz'"`
+		} `kong:"cmd,help='Print a greeting'"`
+	}
+	parser := kong.Must(&cli)
+
+	t.Run("describes commands and flags", func(t *testing.T) {
+		got, err := Complete(parser, "myApp -", 7)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []Candidate{
+			{Value: "--verbose", Description: "Print more output"},
+			{Value: "-v", Description: "Print more output"},
+			{Value: "--help", Description: "Show context-sensitive help."},
+			{Value: "-h", Description: "Show context-sensitive help."},
+		}, got)
+
+		got, err = Complete(parser, "myApp gr", 8)
+		require.NoError(t, err)
+		assert.Equal(t, []Candidate{{Value: "greet", Description: "Print a greeting"}}, got)
+	})
+
+	t.Run("completes up to the cursor", func(t *testing.T) {
+		got, err := Complete(parser, "myApp greet L --verbose", 13)
+		require.NoError(t, err)
+		assert.Equal(t, []Candidate{{Value: "Liz"}}, got)
+	})
+}
+
+func TestCompleteTrace(t *testing.T) {
+	var cli struct {
+		Greet struct {
+			Loud bool   `kong:""`
+			Name string `kong:"arg,completion-predictor=names"`
+		} `kong:"cmd"`
+	}
+	var buf bytes.Buffer
+	tracer := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	_, err := Complete(kong.Must(&cli), "myApp greet --loud ", 19,
+		WithPredictor("names", complete.PredictSet("Ben", "Liz")),
+		WithTracer(tracer),
+	)
+	require.NoError(t, err)
+
+	trace := buf.String()
+	assert.Contains(t, trace, `msg="tokenized line" all="[greet --loud ]"`)
+	assert.Contains(t, trace, `msg="selected node" path=greet boolFlags="[--loud --help -h]"`)
+	assert.Contains(t, trace, `msg="selected positional slot" position=0 slots=1`)
+	assert.Contains(t, trace, `msg="invoked predictor" target="positional name" predictor=names candidates=2`)
+}
+
+func TestCompleteTimeout(t *testing.T) {
+	var cli struct {
+		Slow    string `kong:"completion-predictor=slow"`
+		Partial string `kong:"completion-predictor=partial"`
+		Sub     struct {
+			Args []string `kong:"arg,optional,completion-predictor=slow"`
+		} `kong:"cmd"`
+	}
+	blocking := make(chan struct{})
+	defer close(blocking)
+	predictors := WithPredictors(map[string]complete.Predictor{
+		"slow": complete.PredictFunc(func(complete.Args) []string {
+			<-blocking
+			return []string{"never"}
+		}),
+		"partial": ContextPredictFunc(func(ctx context.Context, a complete.Args) []string {
+			<-ctx.Done()
+			return []string{"partial"}
+		}),
+	})
+
+	for _, td := range []completeTest{
+		{line: "myApp --slow ", want: []string{}},
+		{line: "myApp --partial ", want: []string{"partial"}},
+		{line: "myApp sub -", want: []string{"--help", "-h", "--slow", "--partial"}},
+	} {
+		t.Run(td.line, func(t *testing.T) {
+			got, err := Complete(kong.Must(&cli), td.line, len(td.line), predictors, WithTimeout(10*time.Millisecond))
+			require.NoError(t, err)
+			assert.ElementsMatch(t, td.want, candidateValues(got))
+		})
+	}
+}
+
+func TestCompletePanickingPredictor(t *testing.T) {
+	var cli struct {
+		Verbose bool     `kong:""`
+		Args    []string `kong:"arg,completion-predictor=panicking"`
+	}
+	panicking := WithPredictor("panicking", complete.PredictFunc(func(complete.Args) []string {
+		panic("oh no")
+	}))
+
+	t.Run("reports to error handler", func(t *testing.T) {
+		var errs []error
+		got, err := Complete(kong.Must(&cli), "myApp -", 7, panicking, WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"--verbose", "--help", "-h"}, candidateValues(got))
+		require.Len(t, errs, 1)
+		var panicErr *PanicError
+		require.ErrorAs(t, errs[0], &panicErr)
+		assert.Equal(t, "oh no", panicErr.Value)
+		assert.EqualError(t, errs[0], "positional args: predictor panicked: oh no")
+	})
+
+	t.Run("records in trace", func(t *testing.T) {
+		var buf bytes.Buffer
+		tracer := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		got, err := Complete(kong.Must(&cli), "myApp ", 6, panicking, WithTracer(tracer))
+		require.NoError(t, err)
+		assert.Empty(t, got)
+		assert.Contains(t, buf.String(), `msg="predictor error" error="positional args: predictor panicked: oh no" stack=`)
+	})
+}
+
+func TestCompleteTolerantMode(t *testing.T) {
+	var cli struct {
+		Foo struct {
+			Broken string `kong:"completion-predictor=missing"`
+			Color  string `kong:"enum='red,green',default=red"`
+		} `kong:"cmd"`
+		Bar struct {
+			Name string `kong:"arg,completion-predictor=names"`
+		} `kong:"cmd"`
+	}
+	names := WithPredictor("names", complete.PredictSet("Ben", "Liz"))
+
+	t.Run("fails by default", func(t *testing.T) {
+		_, err := Complete(kong.Must(&cli), "myApp foo --broken ", 19, names)
+		assert.EqualError(t, err, `no predictor with name "missing"`)
+	})
+
+	t.Run("ignores predictors off the command path", func(t *testing.T) {
+		got, err := Complete(kong.Must(&cli), "myApp bar ", 10, names)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"Ben", "Liz"}, candidateValues(got))
+
+		got, err = Complete(kong.Must(&cli), "myApp foo --color ", 18, names)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"red", "green"}, candidateValues(got))
+	})
+
+	t.Run("degrades in tolerant mode", func(t *testing.T) {
+		var errs []error
+		handler := WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		})
+		for _, td := range []completeTest{
+			{line: "myApp bar ", want: []string{"Ben", "Liz"}},
+			{line: "myApp foo --color ", want: []string{"red", "green"}},
+			{line: "myApp foo --broken ", want: []string{}},
+		} {
+			got, err := Complete(kong.Must(&cli, kong.Name("myApp")), td.line, len(td.line), names, handler, WithTolerantMode())
+			require.NoError(t, err)
+			assert.ElementsMatch(t, td.want, candidateValues(got))
+		}
+		require.NotEmpty(t, errs)
+		assert.EqualError(t, errs[0], `myApp foo --broken: no predictor with name "missing"`)
+	})
+}
+
+func TestRegisterCompleteCmd(t *testing.T) {
+	var cli struct {
+		Style string `enum:"formal,casual" default:"casual"`
+		Greet struct {
+			Name string `arg:""`
+		} `cmd:"" help:"Greet someone."`
+		Wave struct{} `cmd:""`
+	}
+	for _, td := range []struct {
+		words []string
+		want  []string
+	}{
+		{words: nil, want: []string{"greet\tGreet someone.", "wave"}},
+		{words: []string{"gr"}, want: []string{"greet\tGreet someone."}},
+		{words: []string{"--style", "f"}, want: []string{"formal"}},
+		{words: []string{"--style=c"}, want: []string{"casual"}},
+		{words: []string{"greet", "my name", ""}, want: nil},
+	} {
+		t.Run(strings.Join(td.words, " "), func(t *testing.T) {
+			origArgs := os.Args
+			defer func() { os.Args = origArgs }()
+			os.Args = append([]string{"app", completeCmd}, td.words...)
+
+			var buf bytes.Buffer
+			parser := kong.Must(&cli, kong.Writers(&buf, &buf))
+			exitCode := -1
+			Register(parser, WithExitFunc(func(code int) { exitCode = code }))
+			assert.Equal(t, 0, exitCode)
+			assert.ElementsMatch(t, td.want, strings.FieldsFunc(buf.String(), func(r rune) bool { return r == '\n' }))
+		})
+	}
+}
+
+func candidateValues(candidates []Candidate) []string {
+	values := make([]string, len(candidates))
+	for i, c := range candidates {
+		values[i] = c.Value
+	}
+	return values
+}
+
 func Test_tagPredictor(t *testing.T) {
 	t.Run("nil", func(t *testing.T) {
 		got, err := tagPredictor(nil, nil, nil)
@@ -312,6 +517,7 @@ func setLineAndPoint(t *testing.T, line string) func() {
 	origPoint, hasOrigPoint := os.LookupEnv(envPoint)
 	require.NoError(t, os.Setenv(envLine, line))
 	require.NoError(t, os.Setenv(envPoint, strconv.Itoa(len(line))))
+	t.Setenv(protocolEnvVar, strconv.Itoa(protocolVersion))
 	return func() {
 		t.Helper()
 		require.NoError(t, os.Unsetenv(envLine))
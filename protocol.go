@@ -0,0 +1,87 @@
+package kongcompletion
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+
+	"github.com/alecthomas/kong"
+)
+
+// protocolVersion is the version of the interface between the completion
+// scripts and the binary, i.e. how the binary is invoked, and what it prints.
+// It must be incremented whenever that changes incompatibly, so that users
+// are told to regenerate their outdated scripts.
+const protocolVersion = 1
+
+// protocolEnvVar is set by the completion scripts when invoking the binary.
+const protocolEnvVar = "KONG_COMPLETION_PROTOCOL"
+
+// ProtocolEnv is the environment variable assignment that tells the binary
+// which protocol version a script speaks.
+func (templateData) ProtocolEnv() string {
+	return protocolEnvVar + "=" + strconv.Itoa(protocolVersion)
+}
+
+// checkProtocol detects whether the completion request comes from a shell
+// script that was generated for another protocol version. In that case, it
+// hints the user to regenerate the script, but only the first time, in order
+// not to clutter the terminal on every TAB press. Scripts that don’t pass a
+// version predate the protocol, so they are outdated too.
+func checkProtocol(parser *kong.Kong, tracer *slog.Logger) {
+	scriptVersion := os.Getenv(protocolEnvVar)
+	if scriptVersion == strconv.Itoa(protocolVersion) {
+		return
+	}
+	if scriptVersion == "" {
+		scriptVersion = "0"
+	}
+	tracer.Warn("protocol mismatch", "script", scriptVersion, "binary", protocolVersion)
+	if !markProtocolHint(parser.Model.Name, scriptVersion) {
+		return
+	}
+	regenerate := "regenerate it"
+	if path := completionCommandPath(parser.Model.Node); path != "" {
+		regenerate = "regenerate it via `" + path + "`"
+	}
+	_, _ = fmt.Fprintf(parser.Stderr, "\n%s: the tab completion script is outdated, please %s.\n", parser.Model.Name, regenerate)
+}
+
+// markProtocolHint records that the hint for the script version was shown. It
+// returns false if that was already the case before, or if it can’t be
+// recorded.
+func markProtocolHint(binName string, scriptVersion string) bool {
+	dir, err := CacheDir(binName)
+	if err != nil {
+		return false
+	}
+	if os.MkdirAll(dir, 0o755) != nil {
+		return false
+	}
+	name := fmt.Sprintf("protocol-%s-%d.hinted", filepath.Base(scriptVersion), protocolVersion)
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return false
+	}
+	return f.Close() == nil
+}
+
+// completionCommandPath returns the full path of the Completion subcommand in
+// the app, if there is one.
+func completionCommandPath(node *kong.Node) string {
+	for _, child := range node.Children {
+		if child == nil {
+			continue
+		}
+		if child.Target.IsValid() && child.Target.Type() == reflect.TypeFor[Completion]() {
+			return child.FullPath()
+		}
+		if path := completionCommandPath(child); path != "" {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package kongcompletion
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckProtocol(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var cli struct {
+		Tools struct {
+			Completion Completion `cmd:""`
+		} `cmd:""`
+	}
+	var stderr bytes.Buffer
+	parser := kong.Must(&cli, kong.Name("greet"), kong.Writers(&bytes.Buffer{}, &stderr))
+	tracer := slog.New(slog.DiscardHandler)
+
+	t.Setenv(protocolEnvVar, "1")
+	checkProtocol(parser, tracer)
+	assert.Empty(t, stderr.String())
+
+	hint := "\ngreet: the tab completion script is outdated, please regenerate it via `greet tools completion`.\n"
+	for _, version := range []string{"0", "2"} {
+		stderr.Reset()
+		t.Setenv(protocolEnvVar, version)
+		checkProtocol(parser, tracer)
+		assert.Equal(t, hint, stderr.String(), version)
+
+		// The hint is only shown once.
+		stderr.Reset()
+		checkProtocol(parser, tracer)
+		assert.Empty(t, stderr.String(), version)
+	}
+
+	// Scripts from before the protocol don’t pass a version at all.
+	require.NoError(t, os.Unsetenv(protocolEnvVar))
+	checkProtocol(parser, tracer)
+	assert.Empty(t, stderr.String(), "was hinted for version 0 already")
+	require.NoError(t, os.RemoveAll(os.Getenv("XDG_CACHE_HOME")))
+	checkProtocol(parser, tracer)
+	assert.Equal(t, hint, stderr.String())
+}
+
+func TestRegisterChecksProtocol(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	var cli struct {
+		Wave struct{} `cmd:""`
+	}
+
+	for name, td := range map[string]struct {
+		args     []string
+		line     string
+		wantHint bool
+	}{
+		"shell script":        {args: []string{"greet"}, line: "greet ", wantHint: true},
+		"complete subcommand": {args: []string{"greet", completeCmd, ""}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			os.Args = td.args
+			t.Setenv(envLine, td.line)
+			t.Setenv(protocolEnvVar, "")
+			require.NoError(t, os.Unsetenv(protocolEnvVar))
+			var stdout, stderr bytes.Buffer
+			parser := kong.Must(&cli, kong.Name("greet"), kong.Writers(&stdout, &stderr))
+			Register(parser, WithExitFunc(func(int) {}))
+			assert.Equal(t, "wave\n", stdout.String())
+			assert.Equal(t, td.wantHint, strings.Contains(stderr.String(), "outdated"), stderr.String())
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package kongcompletion
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/kong"
+)
+
+// LineEditor reads the lines that are entered in a REPL. It returns io.EOF
+// when the input ends, e.g. when the user presses Ctrl-D. Line editing
+// libraries such as github.com/peterh/liner satisfy this interface, and can
+// be hooked up with the completions of the REPL via WordCompleter or Do.
+type LineEditor interface {
+	Prompt(prompt string) (string, error)
+}
+
+// REPL is an interactive mode for a kong app, in which the user enters the
+// commands of the app (without the binary name) line by line. The lines can
+// be tab completed the same way as in the shell.
+type REPL struct {
+	// Prompt is displayed at the beginning of every line.
+	Prompt string
+
+	// Run executes a parsed command line. It defaults to running the
+	// selected command via (*kong.Context).Run.
+	Run func(ctx *kong.Context) error
+
+	parser  *kong.Kong
+	editor  LineEditor
+	options []Option
+}
+
+// NewREPL returns a REPL for the kong app, which reads the lines via the
+// editor. The options are the same that are passed to Register, e.g. custom
+// predictors.
+func NewREPL(parser *kong.Kong, editor LineEditor, opt ...Option) *REPL {
+	return &REPL{
+		Prompt: parser.Model.Name + "> ",
+		Run: func(ctx *kong.Context) error {
+			return ctx.Run()
+		},
+		parser:  parser,
+		editor:  editor,
+		options: opt,
+	}
+}
+
+// Loop reads and executes lines until the input ends. Errors of the executed
+// commands are printed, whereas errors of the line editor end the loop.
+func (r *REPL) Loop() error {
+	for {
+		line, err := r.editor.Prompt(r.Prompt)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = r.Execute(line)
+		if err != nil {
+			_, _ = fmt.Fprintf(r.parser.Stderr, "%s: error: %v\n", r.parser.Model.Name, err)
+		}
+	}
+}
+
+// replExit is the panic value via which the REPL intercepts kong’s attempts
+// to exit the process, e.g. after printing the help.
+type replExit struct {
+	code int
+}
+
+// Execute parses a line and runs the command. Blank lines are ignored. It
+// waits for predictor functions (see WithPredictorFunc) that are still
+// running, since they temporarily apply the completed line to the struct of
+// the app.
+func (r *REPL) Execute(line string) (err error) {
+	args, err := splitWords(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	appMu.Lock()
+	defer appMu.Unlock()
+
+	exit := r.parser.Exit
+	r.parser.Exit = func(code int) {
+		panic(replExit{code: code})
+	}
+	defer func() {
+		r.parser.Exit = exit
+		if v := recover(); v != nil {
+			e, ok := v.(replExit)
+			if !ok {
+				panic(v)
+			}
+			if e.code != 0 {
+				err = fmt.Errorf("exit status %d", e.code)
+			}
+		}
+	}()
+	ctx, err := r.parser.Parse(args)
+	if err != nil {
+		return err
+	}
+	return r.Run(ctx)
+}
+
+// Complete returns the candidates for completing line, where pos is the
+// cursor position within line. The line is split into words the same way as
+// by Execute.
+func (r *REPL) Complete(line string, pos int) ([]Candidate, error) {
+	candidates, _, _, err := r.complete(line, pos)
+	return candidates, err
+}
+
+// complete computes the candidates for the word under the cursor. Like the
+// engine, it only completes the part after the last `=` of the word, e.g.
+// the value of `--flag=value`. It returns that part, along with the offset
+// in line at which it starts.
+func (r *REPL) complete(line string, pos int) (candidates []Candidate, word string, start int, err error) {
+	if pos < 0 || pos > len(line) {
+		pos = len(line)
+	}
+	line = line[:pos]
+	words, start, _, _ := scanWords(line)
+	if start == len(line) {
+		words = append(words, "")
+	}
+	word = words[len(words)-1]
+	if i := strings.LastIndex(word, "="); i >= 0 {
+		word = word[i+1:]
+		start += strings.LastIndex(line[start:], "=") + 1
+	}
+	a := argsFromFields(append([]string{r.parser.Model.Name}, splitLastEqual(words)...))
+	candidates, err = completeArgs(context.Background(), r.parser, a, r.options...)
+	return candidates, word, start, err
+}
+
+// WordCompleter completes the word at pos. It is compatible with the word
+// completer of github.com/peterh/liner, which replaces the word between head
+// and tail with the chosen candidate.
+func (r *REPL) WordCompleter(line string, pos int) (head string, completions []string, tail string) {
+	if pos < 0 || pos > len(line) {
+		pos = len(line)
+	}
+	candidates, _, start, err := r.complete(line, pos)
+	if err != nil {
+		return line[:pos], nil, line[pos:]
+	}
+	for _, candidate := range candidates {
+		completions = append(completions, candidate.Value)
+	}
+	return line[:start], completions, line[pos:]
+}
+
+// Do completes the word at pos. It is compatible with the AutoCompleter
+// interface of github.com/chzyer/readline, i.e. it returns the remainders of
+// the candidates, along with the length of the word that is completed.
+func (r *REPL) Do(line []rune, pos int) (newLine [][]rune, length int) {
+	if pos < 0 || pos > len(line) {
+		pos = len(line)
+	}
+	text := string(line[:pos])
+	candidates, word, _, err := r.complete(text, len(text))
+	if err != nil {
+		return nil, 0
+	}
+	for _, candidate := range candidates {
+		newLine = append(newLine, []rune(strings.TrimPrefix(candidate.Value, word)))
+	}
+	return newLine, utf8.RuneCountInString(word)
+}
+
+// ReaderEditor is a basic LineEditor that reads lines from a reader, and
+// writes the prompt to a writer. It doesn’t support tab completion, so it is
+// meant for non-interactive input, or as a fallback.
+type ReaderEditor struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+// NewReaderEditor returns a ReaderEditor, e.g. for os.Stdin and os.Stdout.
+func NewReaderEditor(in io.Reader, out io.Writer) *ReaderEditor {
+	return &ReaderEditor{
+		scanner: bufio.NewScanner(in),
+		out:     out,
+	}
+}
+
+// Prompt implements LineEditor
+func (e *ReaderEditor) Prompt(prompt string) (string, error) {
+	_, err := io.WriteString(e.out, prompt)
+	if err != nil {
+		return "", err
+	}
+	if !e.scanner.Scan() {
+		if err := e.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return e.scanner.Text(), nil
+}
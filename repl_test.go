@@ -0,0 +1,132 @@
+package kongcompletion
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type replApp struct {
+	Greet struct {
+		Style string `enum:"formal,casual" default:"casual"`
+		Name  string `arg:"" completion-predictor:"names"`
+	} `cmd:""`
+	Wave struct{} `cmd:""`
+}
+
+func newTestREPL(input string) (*REPL, *bytes.Buffer, *[]string) {
+	var out bytes.Buffer
+	var cli replApp
+	parser := kong.Must(&cli, kong.Name("greet"), kong.Writers(&out, &out))
+	r := NewREPL(parser, NewReaderEditor(strings.NewReader(input), &out),
+		WithPredictor("names", complete.PredictSet("Ben", "Liz")))
+	var executed []string
+	r.Run = func(ctx *kong.Context) error {
+		executed = append(executed, ctx.Command()+" "+cli.Greet.Style+" "+cli.Greet.Name)
+		return nil
+	}
+	return r, &out, &executed
+}
+
+func TestREPLLoop(t *testing.T) {
+	r, out, executed := newTestREPL("greet Ben\n\ngreet --style=formal 'Mr Smith'\nwave --help\nnope\ngreet Liz\n")
+	require.NoError(t, r.Loop())
+
+	assert.Equal(t, []string{
+		"greet <name> casual Ben",
+		"greet <name> formal Mr Smith",
+		"greet <name> casual Liz",
+	}, *executed)
+	assert.Contains(t, out.String(), "Usage: greet wave")
+	assert.Contains(t, out.String(), `greet: error: unexpected argument nope`)
+	assert.True(t, strings.HasSuffix(out.String(), "greet> "))
+}
+
+func TestREPLCompletion(t *testing.T) {
+	r, _, _ := newTestREPL("")
+
+	t.Run("candidates", func(t *testing.T) {
+		got, err := r.Complete("greet --style ", 14)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"formal", "casual"}, candidateValues(got))
+	})
+
+	t.Run("word completer", func(t *testing.T) {
+		head, completions, tail := r.WordCompleter("greet L", 7)
+		assert.Equal(t, "greet ", head)
+		assert.Equal(t, []string{"Liz"}, completions)
+		assert.Equal(t, "", tail)
+
+		head, completions, tail = r.WordCompleter("gr --style=formal", 2)
+		assert.Equal(t, "", head)
+		assert.Equal(t, []string{"greet"}, completions)
+		assert.Equal(t, " --style=formal", tail)
+	})
+
+	t.Run("auto completer", func(t *testing.T) {
+		newLine, length := r.Do([]rune("greet --style f"), 15)
+		assert.Equal(t, [][]rune{[]rune("ormal")}, newLine)
+		assert.Equal(t, 1, length)
+	})
+
+	t.Run("flag values after =", func(t *testing.T) {
+		head, completions, tail := r.WordCompleter("greet --style=f", 15)
+		assert.Equal(t, "greet --style=", head)
+		assert.Equal(t, []string{"formal"}, completions)
+		assert.Equal(t, "", tail)
+
+		newLine, length := r.Do([]rune("greet --style=f"), 15)
+		assert.Equal(t, [][]rune{[]rune("ormal")}, newLine)
+		assert.Equal(t, 1, length)
+
+		newLine, length = r.Do([]rune("greet --style="), 14)
+		assert.ElementsMatch(t, [][]rune{[]rune("formal"), []rune("casual")}, newLine)
+		assert.Equal(t, 0, length)
+	})
+
+	t.Run("quoted arguments", func(t *testing.T) {
+		got, err := r.Complete(`greet --style 'f`, 16)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"formal"}, candidateValues(got))
+
+		got, err = r.Complete(`greet "--style" f`, 17)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"formal"}, candidateValues(got))
+
+		head, _, _ := r.WordCompleter(`greet 'Mr L`, 11)
+		assert.Equal(t, "greet ", head)
+
+		newLine, length := r.Do([]rune(`greet 'Li`), 9)
+		assert.Equal(t, [][]rune{[]rune("z")}, newLine)
+		assert.Equal(t, 2, length, "excludes the quote")
+
+		_, length = r.Do([]rune(`greet 'Mr L`), 11)
+		assert.Equal(t, 4, length)
+	})
+}
+
+func TestREPLCompletionKeepsTheApp(t *testing.T) {
+	r, _, executed := newTestREPL("")
+	var style string
+	r.options = []Option{WithPredictorFunc("names", func(cli *replApp) []string {
+		style = cli.Greet.Style
+		return []string{"Ben"}
+	})}
+	cli := r.parser.Model.Target.Addr().Interface().(*replApp)
+
+	require.NoError(t, r.Execute("greet Liz"))
+	got, err := r.Complete("greet --style=formal ", 21)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Ben"}, candidateValues(got))
+	assert.Equal(t, "formal", style, "the predictor sees the line being completed")
+	assert.Equal(t, "casual", cli.Greet.Style)
+	assert.Equal(t, "Liz", cli.Greet.Name)
+
+	require.NoError(t, r.Execute("wave"))
+	assert.Equal(t, []string{"greet <name> casual Liz", "wave casual "}, *executed)
+}
@@ -0,0 +1,97 @@
+package kongcompletion
+
+import (
+	"errors"
+	"io"
+
+	"github.com/alecthomas/kong"
+)
+
+// ScriptOption is a configuration option for rendering the shell scripts
+type ScriptOption func(*templateData)
+
+// WithBinName the name of the binary that the completions are registered for
+func WithBinName(name string) ScriptOption {
+	return func(d *templateData) {
+		d.BinName = name
+	}
+}
+
+// WithBinPath the path of the binary that is invoked for computing completions
+func WithBinPath(path string) ScriptOption {
+	return func(d *templateData) {
+		d.BinPath = path
+	}
+}
+
+// WithShellDefault whether to fall back to the shell’s default completions
+func WithShellDefault(useShellDefault bool) ScriptOption {
+	return func(d *templateData) {
+		d.UseShellDefault = useShellDefault
+	}
+}
+
+// WithStatic generate a self-contained script, which contains the commands,
+// flags and arguments of the app, so that the shell only needs to invoke the
+// binary for values with a completion-predictor.
+func WithStatic() ScriptOption {
+	return func(d *templateData) {
+		d.Static = true
+	}
+}
+
+func buildTemplateData(parser *kong.Kong, opt ...ScriptOption) templateData {
+	data := templateData{
+		UseShellDefault: true,
+	}
+	if parser != nil && parser.Model != nil {
+		data.BinName = parser.Model.Name
+	}
+	for _, o := range opt {
+		o(&data)
+	}
+	if data.BinPath == "" {
+		// Rely on the binary being resolvable via $PATH.
+		data.BinPath = data.BinName
+	}
+	return data
+}
+
+// Script returns the code for initializing tab completion of a kong app in
+// the given shell. Unlike the Completion command, it doesn’t inspect the
+// running process, so it can be used from build tooling. Unless specified
+// otherwise via the options, the binary name is taken from the kong model,
+// and the binary is assumed to be resolvable via $PATH.
+func Script(parser *kong.Kong, shellName string, opt ...ScriptOption) (string, error) {
+	sh, err := newShellFromString(shellName)
+	if err != nil {
+		return "", err
+	}
+	data := buildTemplateData(parser, opt...)
+	if data.BinName == "" {
+		return "", errors.New("no binary name given")
+	}
+	return data.script(parser, sh)
+}
+
+// script renders the init code of the shell, or the static script.
+func (bi templateData) script(parser *kong.Kong, sh shell) (string, error) {
+	if !bi.Static {
+		return bi.fill(sh.initCode), nil
+	}
+	root, err := buildModel(parser)
+	if err != nil {
+		return "", err
+	}
+	return sh.staticScript(root, bi), nil
+}
+
+// WriteScript writes the output of Script to w.
+func WriteScript(w io.Writer, parser *kong.Kong, shellName string, opt ...ScriptOption) error {
+	script, err := Script(parser, shellName, opt...)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, script+"\n")
+	return err
+}
@@ -0,0 +1,51 @@
+package kongcompletion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScript(t *testing.T) {
+	var cli struct {
+		Completion Completion `kong:"cmd"`
+	}
+	parser := kong.Must(&cli, kong.Name("greet"))
+
+	t.Run("defaults", func(t *testing.T) {
+		got, err := Script(parser, "bash")
+		require.NoError(t, err)
+		assert.Equal(t, "complete -o default -o bashdefault -C 'KONG_COMPLETION_PROTOCOL=1 greet' greet", got)
+	})
+
+	t.Run("explicit binary", func(t *testing.T) {
+		got, err := Script(parser, "bash", WithBinName("hi"), WithBinPath("/opt/hi"), WithShellDefault(false))
+		require.NoError(t, err)
+		assert.Equal(t, "complete -C 'KONG_COMPLETION_PROTOCOL=1 /opt/hi' hi", got)
+	})
+
+	t.Run("quotes binary path", func(t *testing.T) {
+		got, err := Script(parser, "bash", WithBinPath("/opt/my bin/greet"))
+		require.NoError(t, err)
+		assert.Equal(t, `complete -o default -o bashdefault -C 'KONG_COMPLETION_PROTOCOL=1 '\''/opt/my bin/greet'\''' greet`, got)
+
+		got, err = Script(parser, "fish", WithBinPath("/opt/it's/greet"))
+		require.NoError(t, err)
+		assert.Contains(t, got, `    KONG_COMPLETION_PROTOCOL=1 '/opt/it\'s/greet'`+"\n")
+	})
+
+	t.Run("unsupported shell", func(t *testing.T) {
+		_, err := Script(parser, "tcsh")
+		assert.ErrorContains(t, err, "not supported")
+	})
+
+	t.Run("write", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteScript(&buf, parser, "fish", WithBinPath("/opt/greet")))
+		assert.Contains(t, buf.String(), "complete -f -c greet")
+		assert.Contains(t, buf.String(), "    KONG_COMPLETION_PROTOCOL=1 /opt/greet\n")
+	})
+}
@@ -0,0 +1,243 @@
+package kongcompletion
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+)
+
+// completeServerCmd is the pseudo subcommand via which frontends such as
+// editors start a completion Server that communicates via stdin and stdout,
+// e.g. `greet __complete-server`.
+const completeServerCmd = "__complete-server"
+
+// serverPredictorSlots is the maximum number of predictors that a Server
+// runs in the background at the same time.
+const serverPredictorSlots = 32
+
+// Server answers completion requests for a kong app. Unlike Complete, it
+// builds the completion tree only once, and reuses it across requests, so it
+// is suitable for long-running frontends that complete many command lines.
+//
+// Predictors that don’t implement ContextPredictor can’t be stopped at the
+// deadline (see WithTimeout), so they keep running in the background. The
+// Server limits the number of such predictors, and skips further ones until
+// some have finished. Their errors are still passed to the error handler (see
+// WithErrorHandler), which must therefore be safe for concurrent use.
+type Server struct {
+	mu     sync.Mutex
+	parser *kong.Kong
+	opts   *options
+	cmd    complete.Command
+	slots  chan struct{}
+}
+
+// NewServer builds the completion tree of a kong app for a Server. The
+// predictors are resolved on first use, so a misconfigured predictor only
+// fails the requests that it is involved in.
+func NewServer(parser *kong.Kong, opt ...Option) (*Server, error) {
+	if parser == nil || parser.Model == nil {
+		return nil, fmt.Errorf("no kong model given")
+	}
+	opts := buildOptions(opt...)
+	// Every node is on the command path of some request.
+	opts.selected = map[*kong.Node]bool{}
+	_ = kong.Visit(parser.Model.Node, func(n kong.Visitable, next kong.Next) error {
+		if node, ok := n.(*kong.Node); ok {
+			opts.selected[node] = true
+		}
+		return next(nil)
+	})
+	cmd, err := command(parser, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		parser: parser,
+		opts:   opts,
+		cmd:    cmd,
+		slots:  make(chan struct{}, serverPredictorSlots),
+	}, nil
+}
+
+// Complete computes the completion candidates for the words after the binary
+// name, where cursor is the index of the word under the cursor. The words
+// after the cursor are ignored. Requests are processed one at a time.
+func (s *Server) Complete(ctx context.Context, args []string, cursor int) ([]Candidate, error) {
+	if cursor < 0 || cursor >= len(args) {
+		return nil, fmt.Errorf("cursor %d is out of range for %d arguments", cursor, len(args))
+	}
+	words := append([]string{s.parser.Model.Name}, args[:cursor+1]...)
+	a := argsFromFields(splitLastEqual(words))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	complete.Log("Completing words: %q", args[:cursor+1])
+	cancel := s.opts.beginRequest(withPredictorSlots(ctx, s.slots), s.parser, a)
+	defer cancel()
+	node := selectNode(s.parser.Model.Node, a)
+	traceSelection(s.opts.tracer, a, node)
+	return predictCandidates(s.cmd, s.opts, a, node)
+}
+
+// The types below describe the messages of JSON-RPC 2.0, see
+// https://www.jsonrpc.org/specification for reference.
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // Absent for notifications.
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+type completeParams struct {
+	Args   []string `json:"args"`
+	Cursor *int     `json:"cursor,omitempty"` // Defaults to the last argument.
+}
+
+type completeResult struct {
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Serve answers JSON-RPC 2.0 requests that are read from r, one per line, and
+// writes the responses to w, one per line. A line may also hold a batch of
+// requests. The `complete` method takes the `args` after the binary name and
+// the index of the word under the `cursor`, and returns the `candidates` with
+// their `value` and `description`. Serve returns when r is exhausted, or when
+// ctx is done.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		response, ok := s.handleLine(ctx, line)
+		if !ok {
+			continue
+		}
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// handleLine processes a single message or a batch of messages. It reports
+// false if there is nothing to answer, i.e. if there are only notifications.
+func (s *Server) handleLine(ctx context.Context, line []byte) (any, bool) {
+	if trimmed := bytes.TrimLeft(line, " \t\r"); len(trimmed) == 0 || trimmed[0] != '[' {
+		return s.handle(ctx, line)
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(line, &batch); err != nil {
+		return rpcFailure(nil, rpcParseError, err.Error()), true
+	}
+	if len(batch) == 0 {
+		return rpcFailure(nil, rpcInvalidRequest, "empty batch"), true
+	}
+	var responses []rpcResponse
+	for _, message := range batch {
+		if response, ok := s.handle(ctx, message); ok {
+			responses = append(responses, response)
+		}
+	}
+	return responses, len(responses) > 0
+}
+
+// handle processes a single message. It reports false if the message is a
+// notification, which mustn’t be answered.
+func (s *Server) handle(ctx context.Context, message []byte) (rpcResponse, bool) {
+	var req rpcRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		if json.Valid(message) {
+			return rpcFailure(nil, rpcInvalidRequest, "not a JSON-RPC 2.0 request"), true
+		}
+		return rpcFailure(nil, rpcParseError, err.Error()), true
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcFailure(req.ID, rpcInvalidRequest, "not a JSON-RPC 2.0 request"), true
+	}
+	notification := req.ID == nil
+	s.opts.tracer.Debug("server request", "method", req.Method, "id", string(req.ID))
+
+	if req.Method != "complete" {
+		return rpcFailure(req.ID, rpcMethodNotFound, "unknown method "+req.Method), !notification
+	}
+	var params completeParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return rpcFailure(req.ID, rpcInvalidParams, err.Error()), !notification
+	}
+	args := slices.Clone(params.Args)
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	cursor := len(args) - 1
+	if params.Cursor != nil {
+		cursor = *params.Cursor
+	}
+	candidates, err := s.Complete(ctx, args, cursor)
+	if err != nil {
+		return rpcFailure(req.ID, rpcServerError, err.Error()), !notification
+	}
+	return rpcResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  completeResult{Candidates: candidates},
+	}, !notification
+}
+
+func rpcFailure(id json.RawMessage, code int, message string) rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return rpcResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &rpcError{Code: code, Message: message},
+	}
+}
+
+// serve runs a Server on stdin and stdout of the app, as requested via
+// completeServerCmd.
+func serve(parser *kong.Kong, in io.Reader, opt ...Option) error {
+	server, err := NewServer(parser, opt...)
+	if err != nil {
+		return err
+	}
+	return server.Serve(context.Background(), in, parser.Stdout)
+}
@@ -0,0 +1,188 @@
+package kongcompletion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type serverApp struct {
+	Style string `enum:"formal,casual" default:"casual"`
+	Greet struct {
+		Name string `arg:"" completion-predictor:"names"`
+	} `cmd:"" help:"Greet someone."`
+	Wave struct {
+		Broken string `completion-predictor:"missing"`
+	} `cmd:""`
+}
+
+func TestServerComplete(t *testing.T) {
+	calls := 0
+	names := complete.PredictFunc(func(complete.Args) []string {
+		calls++
+		return []string{"Ben", "Liz"}
+	})
+	server, err := NewServer(kong.Must(&serverApp{}, kong.Name("greet")), WithPredictor("names", names))
+	require.NoError(t, err)
+
+	for _, td := range []struct {
+		args   []string
+		cursor int
+		want   []Candidate
+	}{
+		{args: []string{"gr"}, want: []Candidate{{Value: "greet", Description: "Greet someone."}}},
+		{args: []string{"--style", "f"}, cursor: 1, want: []Candidate{{Value: "formal"}}},
+		{args: []string{"--style=c"}, want: []Candidate{{Value: "casual"}}},
+		{args: []string{"greet", "L", "wave"}, cursor: 1, want: []Candidate{{Value: "Liz"}}},
+		{args: []string{"greet", "B"}, cursor: 1, want: []Candidate{{Value: "Ben"}}},
+	} {
+		got, err := server.Complete(context.Background(), td.args, td.cursor)
+		require.NoError(t, err)
+		assert.Equal(t, td.want, got, td.args)
+	}
+	assert.Equal(t, 2, calls)
+
+	_, err = server.Complete(context.Background(), []string{"wave", "--broken", ""}, 2)
+	assert.EqualError(t, err, `no predictor with name "missing"`)
+	_, err = server.Complete(context.Background(), []string{"wave", "--broken", ""}, 2)
+	assert.Error(t, err, "fails on every request")
+	_, err = server.Complete(context.Background(), []string{"greet", ""}, 1)
+	assert.NoError(t, err, "doesn’t affect other requests")
+
+	_, err = server.Complete(context.Background(), []string{"greet"}, 1)
+	assert.EqualError(t, err, "cursor 1 is out of range for 1 arguments")
+}
+
+func TestServerServe(t *testing.T) {
+	server, err := NewServer(kong.Must(&serverApp{}), WithPredictor("names", complete.PredictSet("Ben", "Liz")))
+	require.NoError(t, err)
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"complete","params":{"args":["greet",""]}}`,
+		`{"jsonrpc":"2.0","id":"b","method":"complete","params":{"args":["--style","f","greet"],"cursor":1}}`,
+		`{"jsonrpc":"2.0","method":"complete","params":{"args":["w"]}}`,
+		``,
+		`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"complete","params":{"args":["wave","--broken",""]}}`,
+		`{"id":5}`,
+		`nope`,
+		`[{"jsonrpc":"2.0","id":6,"method":"complete","params":{"args":["w"]}},{"jsonrpc":"2.0","method":"complete","params":{}},1]`,
+		`[{"jsonrpc":"2.0","method":"complete","params":{"args":["w"]}}]`,
+		`[]`,
+	}, "\n")
+	var out bytes.Buffer
+	require.NoError(t, server.Serve(context.Background(), strings.NewReader(in), &out))
+
+	want := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{"candidates":[{"value":"Ben"},{"value":"Liz"}]}}`,
+		`{"jsonrpc":"2.0","id":"b","result":{"candidates":[{"value":"formal"}]}}`,
+		`{"jsonrpc":"2.0","id":3,"error":{"code":-32601,"message":"unknown method shutdown"}}`,
+		`{"jsonrpc":"2.0","id":4,"error":{"code":-32000,"message":"no predictor with name \"missing\""}}`,
+		`{"jsonrpc":"2.0","id":5,"error":{"code":-32600,"message":"not a JSON-RPC 2.0 request"}}`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"invalid character 'o' in literal null (expecting 'u')"}}`,
+		`[{"jsonrpc":"2.0","id":6,"result":{"candidates":[{"value":"wave"}]}},{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"not a JSON-RPC 2.0 request"}}]`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"empty batch"}}`,
+	}
+	got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.JSONEq(t, want[i], got[i])
+	}
+}
+
+func TestServerLimitsBackgroundPredictors(t *testing.T) {
+	release := make(chan struct{})
+	blocking := complete.PredictFunc(func(complete.Args) []string {
+		<-release
+		return []string{"Ben"}
+	})
+	var errs []error
+	server, err := NewServer(kong.Must(&serverApp{}),
+		WithPredictor("names", blocking),
+		WithTimeout(10*time.Millisecond),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+	require.NoError(t, err)
+	server.slots = make(chan struct{}, 1)
+
+	// The first predictor keeps running after the deadline, and takes the slot.
+	_, err = server.Complete(context.Background(), []string{"greet", ""}, 1)
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+	_, err = server.Complete(context.Background(), []string{"greet", ""}, 1)
+	require.NoError(t, err)
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], errTooManyPredictors)
+
+	close(release)
+	assert.Eventually(t, func() bool { return len(server.slots) == 0 }, time.Second, time.Millisecond)
+	got, err := server.Complete(context.Background(), []string{"greet", ""}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []Candidate{{Value: "Ben"}}, got)
+}
+
+func TestServerOverrunningPredictorFunc(t *testing.T) {
+	// Predictor functions without a context keep running after the deadline,
+	// while the server moves on to the next requests (run with -race).
+	release := make(chan struct{})
+	var seen []string
+	var mu sync.Mutex
+	names := WithPredictorFunc("names", func(a complete.Args, cli *serverApp) []string {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, cli.Style+" "+a.Last)
+		return []string{"Ben"}
+	})
+	server, err := NewServer(kong.Must(&serverApp{}), names, WithTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+
+	for _, args := range [][]string{{"greet", "B"}, {"--style=formal", "greet", "L"}, {"greet", ""}} {
+		got, err := server.Complete(context.Background(), args, len(args)-1)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	}
+	close(release)
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(seen) == 3
+	}, time.Second, time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ElementsMatch(t, []string{"casual B", "formal L", "casual "}, seen)
+}
+
+func TestRegisterCompleteServer(t *testing.T) {
+	origArgs, origStdin := os.Args, os.Stdin
+	defer func() { os.Args, os.Stdin = origArgs, origStdin }()
+	os.Args = []string{"app", completeServerCmd}
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdin = r
+	_, err = w.WriteString(`{"jsonrpc":"2.0","id":1,"method":"complete","params":{"args":["gr"]}}` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	var buf bytes.Buffer
+	parser := kong.Must(&serverApp{}, kong.Writers(&buf, &buf))
+	exitCode := -1
+	Register(parser, WithExitFunc(func(code int) { exitCode = code }))
+	assert.Equal(t, 0, exitCode)
+
+	var response struct {
+		Result completeResult `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &response))
+	assert.Equal(t, []Candidate{{Value: "greet", Description: "Greet someone."}}, response.Result.Candidates)
+}
@@ -1,7 +1,7 @@
 package kongcompletion
 
 import (
-	"errors"
+	"fmt"
 )
 
 type shell struct {
@@ -17,6 +17,19 @@ type shell struct {
 
 	// initFilePath is the path of the shell’s default init file, e.g. ~/.bashrc
 	initFilePath string
+
+	// completionFile is the content of a static completion file, as it is
+	// picked up by the shell from its completion directories. If nil, the
+	// initCode is used instead.
+	completionFile *template
+
+	// completionFilePath is the location of the static completion file,
+	// relative to a prefix such as /usr or /usr/local.
+	completionFilePath *template
+
+	// staticScript compiles the model of an app into a self-contained
+	// completion script, which only invokes the binary for dynamic values.
+	staticScript func(root *modelCommand, data templateData) string
 }
 
 var shells = map[string]shell{
@@ -28,24 +41,50 @@ var shells = map[string]shell{
 func newShellFromString(shellName string) (shell, error) {
 	sh, ok := shells[shellName]
 	if !ok {
-		return shell{}, errors.New("")
+		return shell{}, fmt.Errorf("this shell is not supported (%s)", shellName)
 	}
 	return sh, nil
 }
 
+// Note that the argument of `complete -C` is evaluated as shell code, which is
+// why the binary path is quoted twice.
 var bash = shell{
-	name:           "bash",
-	initCode:       tmpl(`complete{{if .UseShellDefault}} -o default -o bashdefault{{ end }} -C {{.BinPath}} {{.BinName}}`),
-	configFileCode: tmpl(`source <({{.BinName}} {{.SubCmdName}} -c bash)`),
-	initFilePath:   "~/.bashrc",
+	name:               "bash",
+	initCode:           tmpl(`complete{{if .UseShellDefault}} -o default -o bashdefault{{ end }} -C {{print .ProtocolEnv " " (.BinPath | shellQuote) | shellQuote}} {{.BinName}}`),
+	configFileCode:     tmpl(`source <({{.BinName}} {{.SubCmdName}} -c bash{{if .Static}} --static{{end}})`),
+	initFilePath:       "~/.bashrc",
+	completionFilePath: tmpl(`share/bash-completion/completions/{{.BinName}}`),
+	staticScript:       staticBash,
 }
 
 var zsh = shell{
 	name: "zsh",
-	initCode: tmpl(`autoload -U +X bashcompinit && bashcompinit
-complete{{if .UseShellDefault}} -o default -o bashdefault{{ end }} -C {{.BinPath}} {{.BinName}}`),
-	configFileCode: tmpl(`source <({{.BinName}} {{.SubCmdName}} -c zsh)`),
+	initCode: tmpl(`_{{.BinName | identifier}}() {
+    local -a candidates
+    candidates=(${(f)"$(COMP_LINE="${words[1,CURRENT]}" {{.ProtocolEnv}} {{.BinPath | shellQuote}})"})
+    compset -P '*='
+    if (( ${#candidates} )); then
+        compadd -a candidates{{if .UseShellDefault}}
+    else
+        _default{{ end }}
+    fi
+}
+compdef _{{.BinName | identifier}} {{.BinName}}`),
+	configFileCode: tmpl(`source <({{.BinName}} {{.SubCmdName}} -c zsh{{if .Static}} --static{{end}})`),
 	initFilePath:   "~/.zshrc",
+	completionFile: tmpl(`#compdef {{.BinName}}
+local -a candidates
+candidates=(${(f)"$(COMP_LINE="${words[1,CURRENT]}" {{.ProtocolEnv}} {{.BinPath | shellQuote}})"})
+compset -P '*='
+if (( ${#candidates} )); then
+    compadd -a candidates{{if .UseShellDefault}}
+else
+    _default{{ end }}
+fi`),
+	completionFilePath: tmpl(`share/zsh/site-functions/_{{.BinName}}`),
+	staticScript: func(root *modelCommand, data templateData) string {
+		return "autoload -U +X bashcompinit && bashcompinit\n" + staticBash(root, data)
+	},
 }
 
 var fish = shell{
@@ -54,9 +93,11 @@ var fish = shell{
     set -lx COMP_LINE (commandline -cp)
     test -z (commandline -ct)
     and set COMP_LINE "$COMP_LINE "
-    {{.BinPath}}
+    {{.ProtocolEnv}} {{.BinPath | fishQuote}}
 end
 complete -f -c {{.BinName}} -a "(__complete_{{.BinName}})"`),
-	configFileCode: tmpl(`{{.BinName}} {{.SubCmdName}} -c fish | source`),
-	initFilePath:   "~/.config/fish/config.fish",
+	configFileCode:     tmpl(`{{.BinName}} {{.SubCmdName}} -c fish{{if .Static}} --static{{end}} | source`),
+	initFilePath:       "~/.config/fish/config.fish",
+	completionFilePath: tmpl(`share/fish/vendor_completions.d/{{.BinName}}.fish`),
+	staticScript:       staticFish,
 }
@@ -0,0 +1,384 @@
+package kongcompletion
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A static script walks the words before the cursor, in order to determine the
+// (sub)command and the positional slot, or the flag whose value is completed.
+// The (sub)command is tracked as a path like `/greet/`, so that the flags of a
+// command can be matched for all of its descendants via the pattern `/greet/*`.
+
+// staticCommand is a command of the model along with its state path.
+type staticCommand struct {
+	*modelCommand
+	path  string
+	scope []staticFlag // All flags that apply to the command, including those of its parents.
+}
+
+// staticFlag is a flag along with the state path of the command it belongs to.
+type staticFlag struct {
+	modelFlag
+	path string
+}
+
+// flattenModel lists the commands of the model in depth-first order.
+func flattenModel(root *modelCommand) []staticCommand {
+	var commands []staticCommand
+	var walk func(cmd *modelCommand, path string, scope []staticFlag)
+	walk = func(cmd *modelCommand, path string, scope []staticFlag) {
+		for _, flag := range cmd.Flags {
+			scope = append(scope, staticFlag{modelFlag: flag, path: path})
+		}
+		commands = append(commands, staticCommand{modelCommand: cmd, path: path, scope: scope})
+		for _, sub := range cmd.Commands {
+			walk(sub, path+sub.Name+"/", scope[:len(scope):len(scope)])
+		}
+	}
+	walk(root, "/", nil)
+	return commands
+}
+
+// ownFlags returns the flags of all commands, each one only once.
+func ownFlags(commands []staticCommand) []staticFlag {
+	var flags []staticFlag
+	for _, cmd := range commands {
+		for _, flag := range cmd.Flags {
+			flags = append(flags, staticFlag{modelFlag: flag, path: cmd.path})
+		}
+	}
+	return flags
+}
+
+var nonIdentifierChars = regexp.MustCompile(`[^[:alnum:]_]`)
+
+// identifier turns a binary name into something that can be used as part of
+// a function name.
+func identifier(s string) string {
+	return nonIdentifierChars.ReplaceAllString(s, "_")
+}
+
+// firstLine returns the first line of a help text.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// staticBash compiles the model into a bash completion script that only
+// invokes the binary for values with dynamic predictors. It works in zsh too,
+// via bashcompinit.
+func staticBash(root *modelCommand, data templateData) string {
+	fn := "_" + identifier(data.BinName) + "_complete"
+	commands := flattenModel(root)
+	b := &strings.Builder{}
+	w := func(format string, a ...any) {
+		_, _ = fmt.Fprintf(b, format+"\n", a...)
+	}
+	words := func(words []string) string {
+		quoted := make([]string, len(words))
+		for i, word := range words {
+			quoted[i] = shellQuote(word)
+		}
+		return strings.Join(quoted, " ")
+	}
+	action := func(v modelValue) string {
+		switch {
+		case v.Predictor != "":
+			return fn + "_lines \"$(COMP_LINE=\"$COMP_LINE\" COMP_POINT=\"$COMP_POINT\" " + data.ProtocolEnv() + " " + shellQuote(data.BinPath) + " 2>/dev/null)\""
+		case v.Command != nil:
+			return fn + "_lines \"$(" + words(v.Command) + " 2>/dev/null)\""
+		case v.Values != nil:
+			return fn + "_words " + words(v.Values)
+		case v.Hint == hintFile:
+			return fn + "_files -f"
+		case v.Hint == hintDir:
+			return fn + "_files -d"
+		}
+		return ""
+	}
+
+	w("# Completion for %s, generated from its command-line model.", data.BinName)
+	w("%s_words() {", fn)
+	w(`    local word`)
+	w(`    for word in "$@"; do`)
+	w(`        [[ "$word" == "$cur"* ]] && COMPREPLY+=("$word")`)
+	w(`    done`)
+	w(`}`)
+	w("%s_lines() {", fn)
+	w(`    local word`)
+	w(`    while IFS= read -r word; do`)
+	w(`        [[ -n "$word" && "$word" == "$cur"* ]] && COMPREPLY+=("$word")`)
+	w(`    done <<<"$1"`)
+	w(`}`)
+	w("%s_files() {", fn)
+	w(`    type compopt &>/dev/null && compopt -o filenames`)
+	w("    %s_lines \"$(compgen \"$1\" -- \"$cur\")\"", fn)
+	w(`}`)
+	w("%s() {", fn)
+	w(`    local cur="${COMP_WORDS[COMP_CWORD]}" word flag="" cmd=/ pos=0 i`)
+	w(`    COMPREPLY=()`)
+	w(`    for ((i = 1; i < COMP_CWORD; i++)); do`)
+	w(`        word="${COMP_WORDS[i]}"`)
+	w(`        if [[ -n "$flag" ]]; then`)
+	w("            # Bash splits `--flag=value` at the `=`, which belongs to the flag.")
+	w(`            [[ "$word" == "=" ]] && continue`)
+	w(`            flag=""`)
+	w(`            continue`)
+	w(`        fi`)
+	w(`        case "$cmd:$word" in`)
+	for _, cmd := range commands {
+		for _, sub := range cmd.Commands {
+			var patterns []string
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				patterns = append(patterns, shellQuote(cmd.path+":"+name))
+			}
+			w("        %s) cmd=%s pos=0 ;;", strings.Join(patterns, " | "), shellQuote(cmd.path+sub.Name+"/"))
+		}
+	}
+	for _, flag := range ownFlags(commands) {
+		if flag.Bool {
+			continue
+		}
+		w("        %s) flag=\"$word\" ;;", bashFlagPatterns(flag))
+	}
+	w(`        *:-*) ;;`)
+	w(`        *) pos=$((pos + 1)) ;;`)
+	w(`        esac`)
+	w(`    done`)
+	w(`    local prefix=""`)
+	w(`    if [[ -n "$flag" && "$cur" == "=" ]]; then`)
+	w(`        cur=""`)
+	w(`    elif [[ -z "$flag" && "$cur" == --*=* ]]; then`)
+	w("        # Without `=` in COMP_WORDBREAKS (e.g. in zsh), the value is part of the word.")
+	w(`        flag="${cur%%=*}" prefix="${cur%%=*}=" cur="${cur#*=}"`)
+	w(`    fi`)
+	w(`    if [[ -n "$flag" ]]; then`)
+	w(`        case "$cmd:$flag" in`)
+	for _, flag := range ownFlags(commands) {
+		if a := action(flag.Value); a != "" && !flag.Bool {
+			w("        %s) %s ;;", bashFlagPatterns(flag), a)
+		}
+	}
+	w(`        esac`)
+	w(`        [[ -n "$prefix" ]] && COMPREPLY=("${COMPREPLY[@]/#/$prefix}")`)
+	w(`        return 0`)
+	w(`    fi`)
+	w(`    if [[ "$cur" == -* ]]; then`)
+	w(`        case "$cmd" in`)
+	for _, cmd := range commands {
+		var names []string
+		for _, flag := range cmd.scope {
+			names = append(names, flag.flagNames()...)
+		}
+		if len(names) > 0 {
+			w("        %s) %s_words %s ;;", shellQuote(cmd.path), fn, words(names))
+		}
+	}
+	w(`        esac`)
+	w(`        return 0`)
+	w(`    fi`)
+	w(`    case "$cmd" in`)
+	for _, cmd := range commands {
+		var names []string
+		for _, sub := range cmd.Commands {
+			names = append(names, sub.Name)
+			names = append(names, sub.Aliases...)
+		}
+		if len(names) > 0 {
+			w("    %s) %s_words %s ;;", shellQuote(cmd.path), fn, words(names))
+		}
+	}
+	w(`    esac`)
+	w(`    case "$cmd:$pos" in`)
+	for _, cmd := range commands {
+		for i, arg := range cmd.Args {
+			a := action(arg)
+			if a == "" {
+				continue
+			}
+			pattern := shellQuote(cmd.path + ":" + strconv.Itoa(i))
+			if i == len(cmd.Args)-1 && arg.Cumulative {
+				pattern = shellQuote(cmd.path+":") + "*"
+			}
+			w("    %s) %s ;;", pattern, a)
+		}
+	}
+	w(`    esac`)
+	w(`    return 0`)
+	w(`}`)
+	shellDefault := ""
+	if data.UseShellDefault {
+		shellDefault = " -o default -o bashdefault"
+	}
+	w("complete%s -F %s %s", shellDefault, fn, shellQuote(data.BinName))
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// bashFlagPatterns matches the spellings of a flag, within the command that
+// the flag belongs to and all of its descendants.
+func bashFlagPatterns(flag staticFlag) string {
+	var patterns []string
+	for _, name := range flag.flagNames() {
+		patterns = append(patterns, shellQuote(flag.path)+"*:"+shellQuote(name))
+	}
+	return strings.Join(patterns, " | ")
+}
+
+// staticFish compiles the model into a fish completion script that only
+// invokes the binary for values with dynamic predictors.
+func staticFish(root *modelCommand, data templateData) string {
+	fn := "__complete_" + identifier(data.BinName)
+	commands := flattenModel(root)
+	b := &strings.Builder{}
+	w := func(format string, a ...any) {
+		_, _ = fmt.Fprintf(b, format+"\n", a...)
+	}
+	words := func(words []string) string {
+		quoted := make([]string, len(words))
+		for i, word := range words {
+			quoted[i] = fishQuote(word)
+		}
+		return strings.Join(quoted, " ")
+	}
+	described := func(pairs [][2]string) string {
+		quoted := make([]string, 0, len(pairs)*2)
+		for _, pair := range pairs {
+			quoted = append(quoted, fishQuote(pair[0]), fishQuote(firstLine(pair[1])))
+		}
+		return strings.Join(quoted, " ")
+	}
+	action := func(v modelValue) string {
+		switch {
+		case v.Predictor != "":
+			return fn + "_dynamic"
+		case v.Command != nil:
+			return words(v.Command) + " 2>/dev/null"
+		case v.Values != nil:
+			return "printf '%s\\n' " + words(v.Values)
+		case v.Hint == hintFile:
+			return "__fish_complete_path $cur"
+		case v.Hint == hintDir:
+			return "__fish_complete_directories $cur"
+		}
+		return ""
+	}
+
+	w("# Completion for %s, generated from its command-line model.", data.BinName)
+	w("function %s_dynamic", fn)
+	w(`    set -lx COMP_LINE (commandline -cp)`)
+	w(`    test -z (commandline -ct)`)
+	w(`    and set COMP_LINE "$COMP_LINE "`)
+	w("    %s %s", data.ProtocolEnv(), fishQuote(data.BinPath))
+	w(`end`)
+	w("function %s", fn)
+	w(`    set -l words (commandline -opc)`)
+	w(`    set -e words[1]`)
+	w(`    set -l cur (commandline -ct)`)
+	w(`    set -l cmd /`)
+	w(`    set -l pos 0`)
+	w(`    set -l flag`)
+	w(`    for word in $words`)
+	w(`        if test -n "$flag"`)
+	w(`            set flag`)
+	w(`            continue`)
+	w(`        end`)
+	w(`        switch "$cmd:$word"`)
+	for _, cmd := range commands {
+		for _, sub := range cmd.Commands {
+			var patterns []string
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				patterns = append(patterns, fishQuote(cmd.path+":"+name))
+			}
+			w("            case %s", strings.Join(patterns, " "))
+			w("                set cmd %s", fishQuote(cmd.path+sub.Name+"/"))
+			w("                set pos 0")
+		}
+	}
+	for _, flag := range ownFlags(commands) {
+		if flag.Bool {
+			continue
+		}
+		w("            case %s", fishFlagPatterns(flag))
+		w(`                set flag $word`)
+	}
+	w(`            case '*:-*'`)
+	w(`            case '*'`)
+	w(`                set pos (math $pos + 1)`)
+	w(`        end`)
+	w(`    end`)
+	w(`    if test -n "$flag"`)
+	w(`        switch "$cmd:$flag"`)
+	for _, flag := range ownFlags(commands) {
+		if a := action(flag.Value); a != "" && !flag.Bool {
+			w("            case %s", fishFlagPatterns(flag))
+			w("                %s", a)
+		}
+	}
+	w(`        end`)
+	w(`        return`)
+	w(`    end`)
+	w(`    switch "$cur"`)
+	w(`        case '-*'`)
+	w(`            switch $cmd`)
+	for _, cmd := range commands {
+		var pairs [][2]string
+		for _, flag := range cmd.scope {
+			for _, name := range flag.flagNames() {
+				pairs = append(pairs, [2]string{name, flag.Help})
+			}
+		}
+		if len(pairs) > 0 {
+			w("                case %s", fishQuote(cmd.path))
+			w("                    printf '%%s\\t%%s\\n' %s", described(pairs))
+		}
+	}
+	w(`            end`)
+	w(`            return`)
+	w(`    end`)
+	w(`    switch $cmd`)
+	for _, cmd := range commands {
+		var pairs [][2]string
+		for _, sub := range cmd.Commands {
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				pairs = append(pairs, [2]string{name, sub.Help})
+			}
+		}
+		if len(pairs) > 0 {
+			w("        case %s", fishQuote(cmd.path))
+			w("            printf '%%s\\t%%s\\n' %s", described(pairs))
+		}
+	}
+	w(`    end`)
+	w(`    switch "$cmd:$pos"`)
+	for _, cmd := range commands {
+		for i, arg := range cmd.Args {
+			a := action(arg)
+			if a == "" {
+				continue
+			}
+			pattern := fishQuote(cmd.path + ":" + strconv.Itoa(i))
+			if i == len(cmd.Args)-1 && arg.Cumulative {
+				pattern = fishQuote(cmd.path + ":*")
+			}
+			w("        case %s", pattern)
+			w("            %s", a)
+		}
+	}
+	w(`    end`)
+	w(`end`)
+	w("complete -f -c %s -a \"(%s)\"", fishQuote(data.BinName), fn)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// fishFlagPatterns matches the spellings of a flag, within the command that
+// the flag belongs to and all of its descendants.
+func fishFlagPatterns(flag staticFlag) string {
+	var patterns []string
+	for _, name := range flag.flagNames() {
+		patterns = append(patterns, fishQuote(flag.path+"*:"+name))
+	}
+	return strings.Join(patterns, " ")
+}
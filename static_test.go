@@ -0,0 +1,126 @@
+package kongcompletion
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticApp struct {
+	Verbose bool `short:"v"`
+	Open    struct {
+		Config *os.File `help:"The config file."`
+		Into   string   `type:"existingdir"`
+		Mode   string   `enum:"read,write" default:"read"`
+		Files  []string `arg:"" type:"path"`
+	} `cmd:"" aliases:"o" help:"Open some files."`
+	Tag struct {
+		Name  string `arg:"" completion-predictor-cmd:"git tag --list"`
+		Color string `arg:"" enum:"red,green" default:"red"`
+		User  string `arg:"" optional:"" completion-predictor:"users"`
+	} `cmd:""`
+	Secret struct{} `cmd:"" hidden:""`
+}
+
+func TestBuildModel(t *testing.T) {
+	root, err := buildModel(kong.Must(&staticApp{}, kong.Name("app")))
+	require.NoError(t, err)
+
+	require.Len(t, root.Commands, 2)
+	open, tag := root.Commands[0], root.Commands[1]
+	assert.Equal(t, []string{"o"}, open.Aliases)
+	assert.Equal(t, "Open some files.", open.Help)
+	assert.Equal(t, hintFile, open.Flags[0].Value.Hint)
+	assert.Equal(t, hintDir, open.Flags[1].Value.Hint)
+	assert.Equal(t, modelValue{Name: "files", Hint: hintFile, Cumulative: true, Required: true}, open.Args[0])
+
+	assert.Equal(t, []string{"git", "tag", "--list"}, tag.Args[0].Command)
+	assert.Equal(t, []string{"red", "green"}, tag.Args[1].Values)
+	assert.Equal(t, "users", tag.Args[2].Predictor)
+
+	verbose := root.Flags[1]
+	assert.True(t, verbose.Bool)
+	assert.Equal(t, []string{"--verbose", "-v"}, verbose.flagNames())
+}
+
+func TestStaticScript(t *testing.T) {
+	parser := kong.Must(&staticApp{}, kong.Name("app"))
+
+	t.Run("bash", func(t *testing.T) {
+		got, err := Script(parser, "bash", WithStatic(), WithBinPath("/opt/my app"))
+		require.NoError(t, err)
+		for _, line := range []string{
+			`        /:open | /:o) cmd=/open/ pos=0 ;;`,
+			`        /open/*:--config) _app_complete_files -f ;;`,
+			`        /open/*:--into) _app_complete_files -d ;;`,
+			`    /open/:*) _app_complete_files -f ;;`,
+			`    /tag/:0) _app_complete_lines "$(git tag --list 2>/dev/null)" ;;`,
+			`    /tag/:1) _app_complete_words red green ;;`,
+			`    /tag/:2) _app_complete_lines "$(COMP_LINE="$COMP_LINE" COMP_POINT="$COMP_POINT" KONG_COMPLETION_PROTOCOL=1 '/opt/my app' 2>/dev/null)" ;;`,
+			`complete -o default -o bashdefault -F _app_complete app`,
+		} {
+			assert.Contains(t, got+"\n", line+"\n")
+		}
+		assert.NotContains(t, got, "secret")
+	})
+
+	t.Run("bash --flag=value", func(t *testing.T) {
+		if _, err := exec.LookPath("bash"); err != nil {
+			t.Skip("bash is not installed")
+		}
+		script, err := Script(parser, "bash", WithStatic(), WithBinPath("/nonexistent"))
+		require.NoError(t, err)
+		for _, td := range []struct {
+			words string // The words of the command line, as split by the shell.
+			want  string
+		}{
+			{words: "app open --mode =", want: "read write"},
+			{words: "app open --mode = w", want: "write"},
+			{words: "app open --mode = write --i", want: "--into"},
+			{words: "app open --mode=w", want: "--mode=write"},
+		} {
+			cmd := exec.Command("bash", "--norc", "--noprofile", "-c", script+`
+				COMP_WORDS=(`+td.words+`)
+				COMP_CWORD=$((${#COMP_WORDS[@]} - 1))
+				_app_complete
+				echo "${COMPREPLY[*]}"`)
+			out, err := cmd.Output()
+			require.NoError(t, err)
+			assert.Equal(t, td.want+"\n", string(out), td.words)
+		}
+	})
+
+	t.Run("zsh", func(t *testing.T) {
+		got, err := Script(parser, "zsh", WithStatic())
+		require.NoError(t, err)
+		assert.Contains(t, got, "autoload -U +X bashcompinit && bashcompinit\n")
+		assert.Contains(t, got, "-F _app_complete app")
+	})
+
+	t.Run("fish", func(t *testing.T) {
+		got, err := Script(parser, "fish", WithStatic())
+		require.NoError(t, err)
+		for _, line := range []string{
+			`            case /:open /:o`,
+			`                __fish_complete_directories $cur`,
+			`        case '/open/:*'`,
+			`            printf '%s\t%s\n' open 'Open some files.' o 'Open some files.' tag ''`,
+			`            git tag --list 2>/dev/null`,
+			`complete -f -c app -a "(__complete_app)"`,
+		} {
+			assert.Contains(t, got, line)
+		}
+	})
+
+	t.Run("invalid model", func(t *testing.T) {
+		var cli struct {
+			Name string `completion-predictor-cmd:"'oops"`
+		}
+		_, err := Script(kong.Must(&cli, kong.Name("app")), "bash", WithStatic())
+		assert.ErrorContains(t, err, "app --name: parsing predictor command")
+	})
+}
@@ -2,6 +2,8 @@ package kongcompletion
 
 import (
 	"bytes"
+	"regexp"
+	"strings"
 	gotemplate "text/template"
 )
 
@@ -10,13 +12,20 @@ type templateData struct {
 	BinPath         string // The full path to the binary, e.g. `/usr/bin/greet`
 	SubCmdName      string // The name of the invoked subcommand, e.g. `completion` for `greet completion`.
 	UseShellDefault bool   // Whether to fall back to default shell completions.
+	Static          bool   // Whether to generate a self-contained script from the kong model.
 }
 
 type template gotemplate.Template
 
+var templateFuncs = gotemplate.FuncMap{
+	"shellQuote": shellQuote,
+	"fishQuote":  fishQuote,
+	"identifier": identifier,
+}
+
 // tmpl compiles a template from the given input string. It panics if the text is malformed.
 func tmpl(tmpl string) *template {
-	t, err := gotemplate.New("").Parse(tmpl)
+	t, err := gotemplate.New("").Funcs(templateFuncs).Parse(tmpl)
 	if err != nil {
 		panic(err)
 	}
@@ -31,3 +40,22 @@ func (bi templateData) fill(t *template) string {
 	}
 	return result.String()
 }
+
+// safeShellWord matches strings that don’t need quoting in shell code.
+var safeShellWord = regexp.MustCompile(`^[[:alnum:]_./:@%+=,-]+$`)
+
+// shellQuote quotes a string for bash or zsh, unless it’s safe anyway.
+func shellQuote(s string) string {
+	if safeShellWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes a string for fish, unless it’s safe anyway.
+func fishQuote(s string) string {
+	if safeShellWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
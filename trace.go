@@ -0,0 +1,70 @@
+package kongcompletion
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+)
+
+// traceEnvVar can be set to the path of a file, into which Register then
+// writes a trace of the completion decisions.
+const traceEnvVar = "KONG_COMPLETION_TRACE"
+
+// WithTracer record the decisions of the completion engine (at debug level)
+func WithTracer(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.tracer = logger
+	}
+}
+
+// openTraceFile returns a logger that appends to the file at path.
+func openTraceFile(path string) (*slog.Logger, func() error, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	handler := slog.NewJSONHandler(f, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(handler), f.Close, nil
+}
+
+// traceSelection records how the command line was interpreted.
+func traceSelection(tracer *slog.Logger, a complete.Args, node *kong.Node) {
+	tracer.Debug("tokenized line",
+		"all", a.All,
+		"completed", a.Completed,
+		"last", a.Last,
+	)
+	var boolFlags, argFlags []*kong.Flag
+	for n := node; n != nil; n = n.Parent {
+		b, nb := boolAndNonBoolFlags(n.Flags)
+		boolFlags = append(boolFlags, b...)
+		argFlags = append(argFlags, nb...)
+	}
+	tracer.Debug("selected node",
+		"path", node.Path(),
+		"boolFlags", flagNamesWithHyphens(boolFlags...),
+		"argFlags", flagNamesWithHyphens(argFlags...),
+	)
+}
+
+// predictorName returns a human-readable name of the predictor for a value.
+func predictorName(value *kong.Value, vars kong.Vars) string {
+	switch {
+	case value.Tag.Has(predictorTag):
+		name, err := interpolate(value.Tag.Get(predictorTag), vars.CloneWith(value.Tag.Vars), nil)
+		if err != nil {
+			return value.Tag.Get(predictorTag)
+		}
+		return name
+	case value.Tag.Has(predictorCmdTag):
+		return "(command)"
+	case value.IsBool():
+		return "(nothing)"
+	case value.Enum != "":
+		return "(enum)"
+	default:
+		return "(anything)"
+	}
+}
@@ -0,0 +1,96 @@
+package kongcompletion
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/alecthomas/kong"
+)
+
+// Validate checks the completion configuration of a kong app, and reports all
+// problems at once, e.g. references to predictors that don’t exist,
+// predictors that are registered but never used, or ambiguous spellings of
+// commands. Each problem is prefixed with the command path of the respective
+// flag or argument. It returns nil if there are no problems. References from
+// flags or commands with disabled completion aren’t checked, but they count
+// as uses of the predictors.
+//
+// Validate is supposed to be used in unit tests, since Register can only
+// report problems at the time of a completion request.
+func Validate(parser *kong.Kong, opt ...Option) error {
+	if parser == nil || parser.Model == nil {
+		return nil
+	}
+	opts := buildOptions(opt...)
+	opts.parser = parser
+	v := &validator{
+		opts: opts,
+		used: map[string]bool{},
+	}
+	v.validateNode(parser.Model.Node, nil, true)
+
+	for _, name := range slices.Sorted(maps.Keys(opts.predictors)) {
+		if !v.used[name] {
+			v.problems = append(v.problems, fmt.Errorf("predictor %q is registered but never used", name))
+		}
+	}
+	return errors.Join(v.problems...)
+}
+
+type validator struct {
+	opts     *options
+	used     map[string]bool // The names of the predictors that are referenced.
+	problems []error
+}
+
+// validateNode validates a node and all its children. If the completion of
+// the node is disabled, only the references to predictors are collected.
+func (v *validator) validateNode(node *kong.Node, vars kong.Vars, enabled bool) {
+	vars = vars.CloneWith(node.Vars())
+	path := node.FullPath()
+
+	for _, flag := range node.Flags {
+		if flag == nil {
+			continue
+		}
+		v.validateValue(path+" --"+flag.Name, flag.Value, vars, enabled && isCompletionEnabled(flag.Tag))
+	}
+	for _, arg := range node.Positional {
+		v.validateValue(path+" <"+arg.Name+">", arg, vars, enabled)
+	}
+	commands := map[string]*kong.Node{}
+	for _, child := range node.Children {
+		if child == nil {
+			continue
+		}
+		if !enabled || !isCompletionEnabled(child.Tag) {
+			v.validateNode(child, vars, false)
+			continue
+		}
+		for _, spelling := range append([]string{child.Name}, child.Aliases...) {
+			if other, ok := commands[spelling]; ok && other != child {
+				v.problems = append(v.problems, fmt.Errorf("%s: command spelling %s is used by both %s and %s", path, spelling, other.Name, child.Name))
+			}
+			commands[spelling] = child
+		}
+		v.validateNode(child, vars, true)
+	}
+}
+
+func (v *validator) validateValue(path string, value *kong.Value, vars kong.Vars, enabled bool) {
+	if value.Tag.Has(predictorTag) {
+		name, err := interpolate(value.Tag.Get(predictorTag), vars.CloneWith(value.Tag.Vars), nil)
+		if err == nil {
+			v.used[name] = true
+		}
+	}
+	if !enabled {
+		return
+	}
+	_, err := valuePredictor(value, v.opts.predictors, vars)
+	if err != nil {
+		v.problems = append(v.problems, fmt.Errorf("%s: %w", path, err))
+	}
+}
@@ -0,0 +1,55 @@
+package kongcompletion
+
+import (
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/posener/complete"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		var cli struct {
+			Foo struct {
+				Bar string `kong:"completion-predictor=${kind}s,set=kind=thing"`
+			} `kong:"cmd"`
+		}
+		err := Validate(kong.Must(&cli), WithPredictor("things", complete.PredictAnything))
+		assert.NoError(t, err)
+	})
+
+	t.Run("reports all problems", func(t *testing.T) {
+		var cli struct {
+			Verbose bool `kong:"short=v"`
+			Foo     struct {
+				Bar string `kong:"completion-predictor=missing"`
+				Baz string `kong:"completion-predictor=${undefined}"`
+			} `kong:"cmd"`
+			Bar struct{} `kong:"cmd,aliases=qux"`
+			Qux struct {
+				Arg    string `kong:"arg,completion-predictor=also-missing"`
+				Cmd    string `completion-predictor-cmd:"echo 'oops"`
+				Hidden string `kong:"completion-predictor=missing,completion-enabled=false"`
+				Secret string `kong:"completion-predictor=secrets,completion-enabled=false"`
+			} `kong:"cmd"`
+			Internal struct {
+				Arg string `kong:"arg,completion-predictor=internals"`
+			} `kong:"cmd,completion-enabled=false"`
+		}
+		err := Validate(kong.Must(&cli, kong.Name("test")),
+			WithPredictor("unused", complete.PredictAnything),
+			WithPredictor("things", complete.PredictAnything),
+			WithPredictor("secrets", complete.PredictAnything),
+			WithPredictor("internals", complete.PredictAnything),
+		)
+		assert.EqualError(t, err, ""+
+			`test foo --bar: no predictor with name "missing"`+"\n"+
+			`test foo --baz: interpolating predictor name "${undefined}": undefined variable ${undefined}`+"\n"+
+			`test: command spelling qux is used by both bar and qux`+"\n"+
+			`test qux --cmd: parsing predictor command "echo 'oops": unterminated quote`+"\n"+
+			`test qux <arg>: no predictor with name "also-missing"`+"\n"+
+			`predictor "things" is registered but never used`+"\n"+
+			`predictor "unused" is registered but never used`)
+	})
+}